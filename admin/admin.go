@@ -0,0 +1,104 @@
+// Package admin serves Pauling's admin HTTP endpoints: health checks,
+// readiness, metrics and an API for managing servers.
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/TF2Stadium/Pauling/config"
+	"github.com/TF2Stadium/Pauling/database"
+	"github.com/TF2Stadium/Pauling/helpers"
+	"github.com/TF2Stadium/Pauling/metrics"
+	"github.com/TF2Stadium/Pauling/mq"
+	"github.com/TF2Stadium/Pauling/rpc"
+	"github.com/TF2Stadium/Pauling/server"
+)
+
+var mux = http.NewServeMux()
+
+func init() {
+	mux.HandleFunc("/healthz", healthz)
+	mux.HandleFunc("/readyz", readyz)
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/servers", authenticated(listServers))
+	mux.Handle("/servers/", authenticated(serverHandler))
+}
+
+// Start serves the admin endpoints on addr
+func Start(addr string) {
+	helpers.Logger.Info("Serving admin endpoints on %s", addr)
+	go func() {
+		helpers.Logger.Fatal(http.ListenAndServe(addr, mux))
+	}()
+}
+
+type status struct {
+	RPC      string
+	Events   string
+	Postgres string
+	SQLite   string
+	Listener string
+
+	healthy bool // no component is dead
+	ready   bool // every component is working
+}
+
+func errString(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
+
+// eventsState returns the state of the AMQP event sink, treating it as
+// connected if it isn't used
+func eventsState() mq.State {
+	for _, sink := range config.Constants.EventSinks {
+		if sink == "amqp" {
+			return server.EventsState()
+		}
+	}
+	return mq.Connected
+}
+
+func check() status {
+	events := eventsState()
+	st := status{
+		RPC:      rpc.State().String(),
+		Events:   events.String(),
+		Postgres: errString(database.Ping()),
+		SQLite:   errString(server.PingDB()),
+	}
+	listener := server.ListenerState()
+	st.Listener = errString(listener)
+
+	dbsOK := st.Postgres == "ok" && st.SQLite == "ok"
+	// servers which stopped sending logs can come back, so that's only
+	// not ready
+	st.healthy = dbsOK && (listener == nil || listener == server.ErrNoLogs) &&
+		rpc.State() != mq.Closed && events != mq.Closed
+	st.ready = dbsOK && listener == nil && rpc.State() == mq.Connected && events == mq.Connected
+	return st
+}
+
+func writeStatus(w http.ResponseWriter, st status, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(st)
+}
+
+// healthz fails if Pauling can't recover by itself, and should be
+// restarted. Reconnecting to RabbitMQ is still healthy.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	st := check()
+	writeStatus(w, st, st.healthy)
+}
+
+// readyz fails unless Pauling can currently serve RPCs and publish events
+func readyz(w http.ResponseWriter, r *http.Request) {
+	st := check()
+	writeStatus(w, st, st.ready)
+}
@@ -0,0 +1,211 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TF2Stadium/Pauling/config"
+	"github.com/TF2Stadium/Pauling/helpers"
+	"github.com/TF2Stadium/Pauling/server"
+)
+
+type serverInfo struct {
+	LobbyID   uint
+	Host      string
+	Map       string
+	Format    string
+	League    string
+	Whitelist string
+	Started   time.Time
+	Players   int
+	Ended     bool
+	Verifying bool
+}
+
+func info(s *server.Server) serverInfo {
+	return serverInfo{
+		LobbyID:   s.LobbyId,
+		Host:      s.Info.Host,
+		Map:       s.Map,
+		Format:    server.FormatName(s.Type),
+		League:    s.League,
+		Whitelist: s.Whitelist,
+		Started:   s.Started,
+		Players:   s.PlayerCount(),
+		Ended:     s.HasEnded(),
+		Verifying: s.Verifying(),
+	}
+}
+
+// authenticated only lets requests with "Authorization: Bearer <token>"
+// through, using PAULING_ADMIN_TOKEN as the token.
+func authenticated(h http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := config.Constants.AdminToken
+		if token == "" {
+			http.Error(w, "Admin API is disabled", http.StatusNotFound)
+			return
+		}
+
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		h(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+type byLobbyID []serverInfo
+
+func (s byLobbyID) Len() int           { return len(s) }
+func (s byLobbyID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byLobbyID) Less(i, j int) bool { return s[i].LobbyID < s[j].LobbyID }
+
+// GET /servers
+func listServers(w http.ResponseWriter, r *http.Request) {
+	infos := []serverInfo{}
+	for _, s := range server.AllServers() {
+		infos = append(infos, info(s))
+	}
+	sort.Sort(byLobbyID(infos))
+
+	writeJSON(w, infos)
+}
+
+// GET /servers/<lobby id>
+// GET /servers/<lobby id>/logs
+// GET /servers/<lobby id>/stream
+// POST /servers/<lobby id>/<action>, where action is one of reset, kickall,
+// say, whitelist and end. Resets run in the background, and are answered
+// with 202 Accepted.
+func serverHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/servers/"), "/"), "/")
+
+	id, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid lobby ID", http.StatusBadRequest)
+		return
+	}
+
+	s, err := server.GetServer(uint(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if len(parts) == 1 {
+		writeJSON(w, info(s))
+		return
+	}
+
+	switch parts[1] {
+	case "logs":
+		serverLogs(w, r, s)
+		return
+	case "stream":
+		streamLogs(w, r, s)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Actions need to be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	action := parts[1]
+	helpers.Logger.Info("#%d: Admin action %s from %s", s.LobbyId, action, r.RemoteAddr)
+
+	switch action {
+	case "reset":
+		go s.Reset(r.FormValue("changemap") == "true")
+		w.WriteHeader(http.StatusAccepted)
+		return
+	case "kickall":
+		err = s.KickAll()
+	case "say":
+		text, _ := ioutil.ReadAll(r.Body)
+		if len(text) == 0 {
+			http.Error(w, "Nothing to say", http.StatusBadRequest)
+			return
+		}
+		err = s.Say(string(text))
+	case "whitelist":
+		s.ExecWhitelist()
+	case "end":
+		s.End()
+	default:
+		http.Error(w, "Unknown action", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serverLogs writes the lobby's logs after the offset given in the query,
+// and the offset of the next logs in the X-Log-Offset header.
+func serverLogs(w http.ResponseWriter, r *http.Request, s *server.Server) {
+	offset, _ := strconv.Atoi(r.FormValue("offset"))
+	logs, next := s.LogsSince(offset)
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("X-Log-Offset", strconv.Itoa(next))
+	w.Write(logs)
+}
+
+// streamLogs streams the lobby's log lines as server-sent events, till the
+// lobby ends or the client disconnects. The types query parameter can have a
+// comma separated list of line types to stream.
+func streamLogs(w http.ResponseWriter, r *http.Request, s *server.Server) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming isn't supported", http.StatusInternalServerError)
+		return
+	}
+
+	var types []string
+	if t := r.FormValue("types"); t != "" {
+		types = strings.Split(t, ",")
+	}
+
+	stream := s.StreamLogs(types...)
+	defer stream.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	closed := r.Context().Done()
+	for {
+		select {
+		case line, ok := <-stream.C:
+			if !ok {
+				return
+			}
+
+			data, _ := json.Marshal(line)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", line.Type, data)
+			flusher.Flush()
+		case <-closed:
+			return
+		}
+	}
+}
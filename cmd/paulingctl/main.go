@@ -0,0 +1,221 @@
+// paulingctl controls a running Pauling, through its RPC queue and admin API.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/rpc"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TF2Stadium/Helen/models/gameserver"
+	rpcpackage "github.com/TF2Stadium/Helen/models/rpc"
+	"github.com/TF2Stadium/Pauling/config"
+	"github.com/streadway/amqp"
+	"github.com/vibhavp/amqp-rpc"
+)
+
+const usage = `Usage: paulingctl [flags] <command> [arguments]
+
+Commands:
+  list                          list managed servers
+  show <lobby>                  show a lobby's server
+  tail [-types t1,t2] <lobby>   follow a lobby's logs
+  reexec [-changemap] <lobby>   execute the lobby's configs again
+  say <lobby> <text>            say something on a lobby's server
+  disallow <lobby> <steamid>    kick a player from a lobby's server
+  end <lobby>                   stop managing a lobby's server
+  verify <host> <rcon password> check if Pauling can manage a server
+
+Flags:
+`
+
+var (
+	amqpURL  = flag.String("amqp", "", "RabbitMQ URL (default $PAULING_RABBITMQ_URL)")
+	queue    = flag.String("queue", "", "RPC queue (default $PAULING_RPC_QUEUE)")
+	adminURL = flag.String("admin", "http://localhost:8003", "admin API URL")
+	token    = flag.String("token", "", "admin API token (default $PAULING_ADMIN_TOKEN)")
+	timeout  = flag.Duration("timeout", time.Minute, "RPC call timeout")
+)
+
+func fatal(v ...interface{}) {
+	fmt.Fprintln(os.Stderr, v...)
+	os.Exit(1)
+}
+
+func main() {
+	config.InitConstants()
+
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *amqpURL == "" {
+		*amqpURL = config.Constants.RabbitMQURL
+	}
+	if *queue == "" {
+		*queue = config.Constants.RPCQueue
+	}
+	if *token == "" {
+		*token = config.Constants.AdminToken
+	}
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	switch cmd, args := args[0], args[1:]; cmd {
+	case "list":
+		printJSON(adminGet("/servers"))
+	case "show":
+		needArgs(args, 1)
+		printJSON(adminGet("/servers/" + strconv.Itoa(int(lobbyID(args[0])))))
+	case "tail":
+		flags := flag.NewFlagSet("tail", flag.ExitOnError)
+		types := flags.String("types", "", "comma separated line types to show, e.g. kill,chat")
+		flags.Parse(args)
+		needArgs(flags.Args(), 1)
+		tail(lobbyID(flags.Arg(0)), *types)
+	case "reexec":
+		flags := flag.NewFlagSet("reexec", flag.ExitOnError)
+		changeMap := flags.Bool("changemap", false, "change the map again")
+		flags.Parse(args)
+		needArgs(flags.Args(), 1)
+		call("Pauling.ReExecConfig", &rpcpackage.Args{Id: lobbyID(flags.Arg(0)), ChangeMap: *changeMap})
+	case "say":
+		needArgs(args, 2)
+		call("Pauling.Say", &rpcpackage.Args{Id: lobbyID(args[0]), Text: strings.Join(args[1:], " ")})
+	case "disallow":
+		needArgs(args, 2)
+		call("Pauling.DisallowPlayer", &rpcpackage.Args{Id: lobbyID(args[0]), SteamId: args[1]})
+	case "end":
+		needArgs(args, 1)
+		call("Pauling.End", &rpcpackage.Args{Id: lobbyID(args[0])})
+	case "verify":
+		needArgs(args, 2)
+		call("Pauling.VerifyInfo", &gameserver.ServerRecord{Host: args[0], RconPassword: args[1]})
+		fmt.Println("OK")
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func needArgs(args []string, n int) {
+	if len(args) < n {
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func lobbyID(arg string) uint {
+	id, err := strconv.ParseUint(arg, 10, 32)
+	if err != nil {
+		fatal("Invalid lobby ID:", arg)
+	}
+	return uint(id)
+}
+
+// call makes an RPC call to Pauling, exiting on errors
+func call(method string, args interface{}) {
+	conn, err := amqp.Dial(*amqpURL)
+	if err != nil {
+		fatal(err)
+	}
+	defer conn.Close()
+
+	codec, err := amqprpc.NewClientCodec(conn, *queue, amqprpc.JSONCodec{})
+	if err != nil {
+		fatal(err)
+	}
+	client := rpc.NewClientWithCodec(codec)
+
+	select {
+	case c := <-client.Go(method, args, &struct{}{}, nil).Done:
+		if c.Error != nil {
+			fatal(c.Error)
+		}
+	case <-time.After(*timeout):
+		fatal(method, "timed out")
+	}
+}
+
+func adminRequest(path string) *http.Response {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(*adminURL, "/")+path, nil)
+	if err != nil {
+		fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+*token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		fatal(resp.Status+":", strings.TrimSpace(string(body)))
+	}
+	return resp
+}
+
+func adminGet(path string) []byte {
+	resp := adminRequest(path)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fatal(err)
+	}
+	return body
+}
+
+func printJSON(data []byte) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		fatal(err)
+	}
+
+	out, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Println(string(out))
+}
+
+// tail prints the lobby's log lines as they're streamed by the admin API
+func tail(id uint, types string) {
+	resp := adminRequest(fmt.Sprintf("/servers/%d/stream?types=%s", id, url.QueryEscape(types)))
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var logLine struct {
+			Type string
+			Text string
+			Time time.Time
+		}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &logLine); err != nil {
+			fatal(err)
+		}
+		fmt.Printf("%s [%s] %s\n", logLine.Time.Format("15:04:05"), logLine.Type, logLine.Text)
+	}
+
+	if err := scanner.Err(); err != nil {
+		fatal(err)
+	}
+}
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDurationJSON(t *testing.T) {
+	t.Parallel()
+	var d Duration
+	assert.NoError(t, json.Unmarshal([]byte(`"2m30s"`), &d))
+	assert.Equal(t, Duration(150*time.Second), d)
+
+	bytes, err := json.Marshal(d)
+	assert.NoError(t, err)
+	assert.Equal(t, `"2m30s"`, string(bytes))
+
+	assert.Error(t, json.Unmarshal([]byte(`"soon"`), &d))
+	assert.Error(t, json.Unmarshal([]byte(`150`), &d))
+}
@@ -36,3 +36,12 @@ func Connect() {
 
 	helpers.Logger.Debug("Connected.")
 }
+
+// Ping checks if the connection to the database is alive
+func Ping() error {
+	return db.Ping()
+}
+
+func Close() error {
+	return db.Close()
+}
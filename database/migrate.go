@@ -0,0 +1,80 @@
+package database
+
+import (
+	"github.com/TF2Stadium/Pauling/helpers"
+)
+
+// migrations create and change the tables owned by Pauling, which are
+// prefixed with pauling_ to keep them apart from Helen's. Only ever append
+// to this, the index+1 is the schema version.
+var migrations = []string{
+	// 1
+	`CREATE TABLE pauling_reports (
+		id         SERIAL PRIMARY KEY,
+		lobby_id   INTEGER NOT NULL,
+		source     VARCHAR(32) NOT NULL,
+		target     VARCHAR(32) NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+		UNIQUE (lobby_id, source, target)
+	)`,
+	// 2
+	`CREATE TABLE pauling_vote_records (
+		id          SERIAL PRIMARY KEY,
+		lobby_id    INTEGER NOT NULL,
+		source      VARCHAR(32) NOT NULL,
+		target      VARCHAR(32) NOT NULL,
+		outcome     VARCHAR(16) NOT NULL,
+		created_at  TIMESTAMP WITH TIME ZONE NOT NULL,
+		resolved_at TIMESTAMP WITH TIME ZONE
+	);
+	CREATE INDEX ON pauling_vote_records (lobby_id);
+	CREATE INDEX ON pauling_vote_records (source);
+	CREATE INDEX ON pauling_vote_records (target)`,
+}
+
+// migrationLock is the key of the advisory lock held while migrating, so
+// Pauling instances starting together don't run the same migrations
+const migrationLock = 0x7061756c // "paul"
+
+// Migrate brings Pauling's tables up to date. All pending migrations run in
+// a single transaction, holding migrationLock until it's committed.
+func Migrate() error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", migrationLock); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("CREATE TABLE IF NOT EXISTS pauling_schema_migrations (version INTEGER PRIMARY KEY)")
+	if err != nil {
+		return err
+	}
+
+	var version int
+	err = tx.QueryRow("SELECT COALESCE(MAX(version), 0) FROM pauling_schema_migrations").Scan(&version)
+	if err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO pauling_schema_migrations (version) VALUES ($1)", i+1); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if version < len(migrations) {
+		helpers.Logger.Info("Migrated the database from version %d to %d", version, len(migrations))
+	}
+	return nil
+}
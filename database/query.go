@@ -1,6 +1,8 @@
 package database
 
 import (
+	"database/sql"
+
 	"github.com/TF2Stadium/Helen/models/lobby/format"
 	"github.com/TF2Stadium/Pauling/helpers"
 )
@@ -48,16 +50,57 @@ func IsReported(lobbyID uint, commID string) (reported bool) {
 	return
 }
 
-func GetTeam(lobbyID uint, lobbyType format.Format, commID string) (team string) {
+func GetTeam(lobbyID uint, lobbyType format.Format, commID string) string {
+	team, _ := GetTeamClass(lobbyID, lobbyType, commID)
+	return team
+}
+
+// GetTeamClass returns the player's team and slot name, which are empty if
+// the player isn't in the lobby
+func GetTeamClass(lobbyID uint, lobbyType format.Format, commID string) (team, class string) {
 	var slot int
 	err := db.QueryRow("SELECT lobby_slots.slot FROM lobby_slots INNER JOIN players ON lobby_slots.player_id = players.id WHERE lobby_slots.lobby_id = $1 AND players.steam_id = $2", lobbyID, commID).Scan(&slot)
 	if err != nil {
-		helpers.Logger.Error(err.Error())
+		// not in the lobby, slot 0 would be a RED slot
+		if err != sql.ErrNoRows {
+			helpers.Logger.Error(err.Error())
+		}
+		return "", ""
 	}
-	team, _, _ = format.GetSlotTeamClass(lobbyType, slot)
+	team, class, _ = format.GetSlotTeamClass(lobbyType, slot)
 	return
 }
 
+// LobbyPlayer is a player in one of a lobby's slots
+type LobbyPlayer struct {
+	SteamID string // community ID
+	Name    string
+	Team    string
+	Class   string
+}
+
+// GetLobbyPlayers returns every player in the lobby's slots
+func GetLobbyPlayers(lobbyID uint, lobbyType format.Format) ([]LobbyPlayer, error) {
+	rows, err := db.Query("SELECT players.steam_id, players.name, lobby_slots.slot FROM lobby_slots INNER JOIN players ON lobby_slots.player_id = players.id WHERE lobby_slots.lobby_id = $1", lobbyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var players []LobbyPlayer
+	for rows.Next() {
+		var player LobbyPlayer
+		var slot int
+
+		if err := rows.Scan(&player.SteamID, &player.Name, &slot); err != nil {
+			return nil, err
+		}
+		player.Team, player.Class, _ = format.GetSlotTeamClass(lobbyType, slot)
+		players = append(players, player)
+	}
+	return players, rows.Err()
+}
+
 func GetSteamIDFromSlot(team, class string, lobbyID uint, lobbyType format.Format) (string, error) {
 	slot, err := format.GetSlot(lobbyType, team, class)
 	if err != nil {
@@ -88,3 +131,12 @@ func GetNameFromSteamID(commID string) (name string) {
 	}
 	return
 }
+
+// GetLobbyLeader returns the community ID of the lobby's creator
+func GetLobbyLeader(lobbyID uint) (commID string) {
+	err := db.QueryRow("SELECT created_by_steam_id FROM lobbies WHERE id = $1", lobbyID).Scan(&commID)
+	if err != nil {
+		helpers.Logger.Error(err.Error())
+	}
+	return
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"time"
+)
+
+// AddReport records source's vote to replace target, returning false if
+// source has already voted for it
+func AddReport(source, target string, lobbyID uint) (bool, error) {
+	res, err := db.Exec(`INSERT INTO pauling_reports (lobby_id, source, target) VALUES ($1, $2, $3)
+		ON CONFLICT (lobby_id, source, target) DO NOTHING`,
+		lobbyID, source, target)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n != 0, err
+}
+
+// HasReported returns whether source has voted to replace target
+func HasReported(source, target string, lobbyID uint) (reported bool) {
+	db.QueryRow("SELECT EXISTS (SELECT 1 FROM pauling_reports WHERE lobby_id = $1 AND source = $2 AND target = $3)",
+		lobbyID, source, target).Scan(&reported)
+	return
+}
+
+// CountReports returns the number of votes to replace target
+func CountReports(target string, lobbyID uint) (count int) {
+	db.QueryRow("SELECT COUNT(*) FROM pauling_reports WHERE lobby_id = $1 AND target = $2", lobbyID, target).Scan(&count)
+	return
+}
+
+// ResetReports deletes all votes to replace target
+func ResetReports(target string, lobbyID uint) error {
+	_, err := db.Exec("DELETE FROM pauling_reports WHERE lobby_id = $1 AND target = $2", lobbyID, target)
+	return err
+}
+
+// Outcomes of votes
+const (
+	VotePending   = "pending"
+	VoteSucceeded = "succeeded"
+	VoteTimedOut  = "timed_out"
+	VoteCancelled = "cancelled" // by DisallowPlayer, or when detaching
+)
+
+// VoteRecord is the audit record of a single !rep vote
+type VoteRecord struct {
+	ID         uint
+	LobbyID    uint
+	Source     string // community ID of the voter
+	Target     string
+	Outcome    string
+	CreatedAt  time.Time
+	ResolvedAt *time.Time // nil while pending
+}
+
+// AddVoteRecord records a vote, with a pending outcome
+func AddVoteRecord(source, target string, lobbyID uint) error {
+	_, err := db.Exec("INSERT INTO pauling_vote_records (lobby_id, source, target, outcome, created_at) VALUES ($1, $2, $3, $4, $5)",
+		lobbyID, source, target, VotePending, time.Now())
+	return err
+}
+
+// ResolveVoteRecords sets the outcome of the pending votes to replace target
+func ResolveVoteRecords(target string, lobbyID uint, outcome string) error {
+	_, err := db.Exec("UPDATE pauling_vote_records SET outcome = $1, resolved_at = $2 WHERE lobby_id = $3 AND target = $4 AND outcome = $5",
+		outcome, time.Now(), lobbyID, target, VotePending)
+	return err
+}
+
+// GetVoteRecords returns the votes in the lobby, and/or with the player as
+// the voter or target, latest first. A lobbyID of 0 or an empty steamID
+// matches everything.
+func GetVoteRecords(lobbyID uint, steamID string) ([]VoteRecord, error) {
+	rows, err := db.Query(`SELECT id, lobby_id, source, target, outcome, created_at, resolved_at
+		FROM pauling_vote_records
+		WHERE ($1 = 0 OR lobby_id = $1) AND ($2 = '' OR source = $2 OR target = $2)
+		ORDER BY id DESC`, lobbyID, steamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []VoteRecord
+	for rows.Next() {
+		var r VoteRecord
+		err := rows.Scan(&r.ID, &r.LobbyID, &r.Source, &r.Target, &r.Outcome, &r.CreatedAt, &r.ResolvedAt)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// connectTest connects to the database in PAULING_TEST_DATABASE_URL and
+// migrates it, skipping the test if it isn't set.
+func connectTest(t *testing.T) {
+	dsn := os.Getenv("PAULING_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("PAULING_TEST_DATABASE_URL isn't set")
+	}
+
+	var err error
+	db, err = sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// running it twice must be a no-op
+	for i := 0; i < 2; i++ {
+		if err := Migrate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReports(t *testing.T) {
+	connectTest(t)
+	var lobbyID uint = 1
+
+	assert.NoError(t, ResetReports("2", lobbyID))
+	_, err := db.Exec("DELETE FROM pauling_vote_records WHERE lobby_id = $1", lobbyID)
+	assert.NoError(t, err)
+
+	added, err := AddReport("1", "2", lobbyID)
+	assert.NoError(t, err)
+	assert.True(t, added)
+
+	added, err = AddReport("1", "2", lobbyID)
+	assert.NoError(t, err)
+	assert.False(t, added)
+
+	added, err = AddReport("3", "2", lobbyID)
+	assert.NoError(t, err)
+	assert.True(t, added)
+
+	assert.True(t, HasReported("1", "2", lobbyID))
+	assert.False(t, HasReported("2", "1", lobbyID))
+	assert.Equal(t, 2, CountReports("2", lobbyID))
+
+	assert.NoError(t, ResetReports("2", lobbyID))
+	assert.Zero(t, CountReports("2", lobbyID))
+	assert.False(t, HasReported("1", "2", lobbyID))
+
+	assert.NoError(t, AddVoteRecord("1", "2", lobbyID))
+	assert.NoError(t, AddVoteRecord("3", "2", lobbyID))
+	assert.NoError(t, ResolveVoteRecords("2", lobbyID, VoteSucceeded))
+	assert.NoError(t, AddVoteRecord("1", "4", lobbyID))
+
+	records, err := GetVoteRecords(lobbyID, "")
+	assert.NoError(t, err)
+	if assert.Len(t, records, 3) {
+		assert.Equal(t, VotePending, records[0].Outcome)
+		assert.Nil(t, records[0].ResolvedAt)
+		assert.Equal(t, VoteSucceeded, records[2].Outcome)
+		assert.NotNil(t, records[2].ResolvedAt)
+	}
+
+	records, err = GetVoteRecords(lobbyID, "3")
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+}
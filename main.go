@@ -3,7 +3,12 @@ package main
 import (
 	"io/ioutil"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/TF2Stadium/Pauling/admin"
 	"github.com/TF2Stadium/Pauling/config"
 	"github.com/TF2Stadium/Pauling/database"
 	"github.com/TF2Stadium/Pauling/helpers"
@@ -33,8 +38,41 @@ func main() {
 
 	database.Connect()
 
-	server.StartListener()
 	server.CreateDB()
+	server.OpenArchive()
+	server.StartListener()
+	server.ResumeServers()
+
+	if config.Constants.AdminAddr != "" {
+		admin.Start(config.Constants.AdminAddr)
+	}
+
+	go rpc.StartRPC(config.Constants.RabbitMQURL)
 
-	rpc.StartRPC(config.Constants.RabbitMQURL)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	helpers.Logger.Info("Received %s, shutting down", <-sig)
+	shutdown()
+}
+
+// shutdown stops accepting new lobbies, detaches from servers and closes
+// all connections, giving up after the shutdown timeout.
+func shutdown() {
+	rpc.StopAccepting()
+
+	done := make(chan struct{})
+	go func() {
+		server.Shutdown(config.Constants.ShutdownMessage)
+		rpc.Close()
+		database.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		helpers.Logger.Info("Shut down cleanly")
+	case <-time.After(config.Constants.ShutdownTimeout):
+		helpers.Logger.Error("Timed out while shutting down")
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,191 @@
+// Package metrics keeps counters about what Pauling is doing, and exports
+// them in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+type metric interface {
+	write(w io.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []metric
+)
+
+func register(m metric) {
+	registryMu.Lock()
+	registry = append(registry, m)
+	registryMu.Unlock()
+}
+
+// Counter is a counter, optionally partitioned by the value of a single label
+type Counter struct {
+	name  string
+	help  string
+	label string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func NewCounter(name, help string) *Counter {
+	return NewCounterVec(name, help, "")
+}
+
+func NewCounterVec(name, help, label string) *Counter {
+	c := &Counter{
+		name:   name,
+		help:   help,
+		label:  label,
+		values: make(map[string]float64),
+	}
+	register(c)
+	return c
+}
+
+// Add adds v to the counter for the given label value, which is ignored for
+// counters without a label.
+func (c *Counter) Add(labelValue string, v float64) {
+	if c.label == "" {
+		labelValue = ""
+	}
+
+	c.mu.Lock()
+	c.values[labelValue] += v
+	c.mu.Unlock()
+}
+
+func (c *Counter) Inc(labelValue string) {
+	c.Add(labelValue, 1)
+}
+
+// Delete removes the counter for a label value, for labels like lobby IDs
+// that stop being relevant.
+func (c *Counter) Delete(labelValue string) {
+	c.mu.Lock()
+	delete(c.values, labelValue)
+	c.mu.Unlock()
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	if c.label == "" {
+		fmt.Fprintf(w, "%s %v\n", c.name, c.values[""])
+		return
+	}
+
+	var labels []string
+	for label := range c.values {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	for _, label := range labels {
+		fmt.Fprintf(w, "%s{%s=%q} %v\n", c.name, c.label, label, c.values[label])
+	}
+}
+
+// Gauge is a value computed every time metrics are collected
+type Gauge struct {
+	name string
+	help string
+	f    func() float64
+}
+
+func NewGauge(name, help string, f func() float64) *Gauge {
+	g := &Gauge{name: name, help: help, f: f}
+	register(g)
+	return g
+}
+
+func (g *Gauge) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %v\n", g.name, g.help, g.name, g.name, g.f())
+}
+
+// Summary keeps the sum and count of observed values
+type Summary struct {
+	name string
+	help string
+
+	mu    sync.Mutex
+	sum   float64
+	count uint64
+}
+
+func NewSummary(name, help string) *Summary {
+	s := &Summary{name: name, help: help}
+	register(s)
+	return s
+}
+
+func (s *Summary) Observe(v float64) {
+	s.mu.Lock()
+	s.sum += v
+	s.count++
+	s.mu.Unlock()
+}
+
+// Since observes the seconds elapsed since start
+func (s *Summary) Since(start time.Time) {
+	s.Observe(time.Since(start).Seconds())
+}
+
+func (s *Summary) write(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s summary\n%s_sum %v\n%s_count %d\n",
+		s.name, s.help, s.name, s.name, s.sum, s.name, s.count)
+}
+
+// WriteTo writes all metrics to w
+func WriteTo(w io.Writer) {
+	registryMu.Lock()
+	metrics := append([]metric(nil), registry...)
+	registryMu.Unlock()
+
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		WriteTo(w)
+	})
+}
+
+var (
+	RconQueries = NewSummary("pauling_rcon_query_duration_seconds", "Time taken by RCON queries.")
+	RconErrors  = NewCounter("pauling_rcon_errors_total", "Failed RCON queries.")
+
+	LogLines = NewCounterVec("pauling_log_lines_total", "Log lines received from a lobby's server.", "lobby")
+
+	EventsPublished = NewCounterVec("pauling_events_published_total", "Events sent to an event sink.", "sink")
+	EventsFailed    = NewCounterVec("pauling_events_failed_total", "Failed attempts to send an event to an event sink.", "sink")
+
+	Reports = NewCounter("pauling_reports_total", "!rep votes filed.")
+
+	Uploads = NewCounterVec("pauling_logstf_uploads_total", "logs.tf uploads, by result.", "result")
+)
+
+// ObserveRcon records an RCON query which started at start, and failed if
+// err isn't nil.
+func ObserveRcon(start time.Time, err error) {
+	RconQueries.Since(start)
+	if err != nil {
+		RconErrors.Inc("")
+	}
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteTo(t *testing.T) {
+	EventsPublished.Inc("amqp")
+	EventsPublished.Add("webhook", 2)
+	Reports.Inc("ignored")
+	ObserveRcon(time.Now(), errors.New("timed out"))
+	NewGauge("pauling_test_gauge", "Test gauge.", func() float64 { return 3 })
+
+	buf := new(bytes.Buffer)
+	WriteTo(buf)
+	out := buf.String()
+
+	assert.Contains(t, out, "# TYPE pauling_events_published_total counter\n")
+	assert.Contains(t, out, `pauling_events_published_total{sink="amqp"} 1`+"\n")
+	assert.Contains(t, out, `pauling_events_published_total{sink="webhook"} 2`+"\n")
+	assert.Contains(t, out, "pauling_reports_total 1\n")
+	assert.Contains(t, out, "pauling_rcon_errors_total 1\n")
+	assert.Contains(t, out, "pauling_rcon_query_duration_seconds_count 1\n")
+	assert.Contains(t, out, "pauling_test_gauge 3\n")
+
+	EventsPublished.Delete("webhook")
+	buf.Reset()
+	WriteTo(buf)
+	assert.NotContains(t, buf.String(), `sink="webhook"`)
+}
@@ -0,0 +1,201 @@
+// Package mq keeps AMQP connections alive across broker restarts and network
+// failures.
+package mq
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TF2Stadium/Pauling/helpers"
+	"github.com/streadway/amqp"
+)
+
+type State int32
+
+const (
+	Disconnected State = iota
+	Connected
+	Reconnecting
+	Closed
+)
+
+func (s State) String() string {
+	switch s {
+	case Connected:
+		return "connected"
+	case Reconnecting:
+		return "reconnecting"
+	case Closed:
+		return "closed"
+	default:
+		return "disconnected"
+	}
+}
+
+// Supervisor dials an AMQP broker, and redials it with backoff whenever the
+// connection is closed. Setup is called on every new connection, and should
+// declare queues, open channels, register consumers etc.
+type Supervisor struct {
+	name  string
+	url   string
+	setup func(*amqp.Connection) error
+
+	mu    sync.Mutex
+	conn  *amqp.Connection
+	ready chan struct{} // closed once connected
+	state *int32
+	done  chan struct{}
+}
+
+func NewSupervisor(name, url string, setup func(*amqp.Connection) error) *Supervisor {
+	return &Supervisor{
+		name:  name,
+		url:   url,
+		setup: setup,
+		ready: make(chan struct{}),
+		state: new(int32),
+		done:  make(chan struct{}),
+	}
+}
+
+// Start makes the first connection, returning an error if that fails.
+// Afterwards, the connection is watched and reestablished in the background.
+func (s *Supervisor) Start() error {
+	conn, err := s.dial()
+	if err != nil {
+		return err
+	}
+
+	s.connected(conn)
+	go s.watch(conn)
+	return nil
+}
+
+func (s *Supervisor) dial() (*amqp.Connection, error) {
+	conn, err := amqp.Dial(s.url)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.setup(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// connected marks conn as the current connection, returning false if the
+// supervisor was closed while it was being dialed.
+func (s *Supervisor) connected(conn *amqp.Connection) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.State() == Closed {
+		conn.Close()
+		return false
+	}
+
+	s.conn = conn
+	atomic.StoreInt32(s.state, int32(Connected))
+	close(s.ready)
+	return true
+}
+
+// disconnected marks the supervisor as reconnecting, so that Wait blocks till
+// a new connection has been made
+func (s *Supervisor) disconnected() {
+	s.mu.Lock()
+	if s.State() == Connected {
+		atomic.StoreInt32(s.state, int32(Reconnecting))
+		s.ready = make(chan struct{})
+	}
+	s.mu.Unlock()
+}
+
+func (s *Supervisor) watch(conn *amqp.Connection) {
+	for {
+		closeErr := <-conn.NotifyClose(make(chan *amqp.Error, 1))
+		if s.State() == Closed {
+			return
+		}
+		helpers.Logger.Warningf("%s: AMQP connection closed: %v", s.name, closeErr)
+
+		s.disconnected()
+
+		var err error
+		backoff := time.Second
+		for {
+			conn, err = s.dial()
+			if err == nil {
+				break
+			}
+			if s.State() == Closed {
+				return
+			}
+
+			helpers.Logger.Errorf("%s: Couldn't reconnect to RabbitMQ: %v", s.name, err)
+			time.Sleep(backoff)
+			if backoff < time.Minute {
+				backoff *= 2
+			}
+		}
+
+		if !s.connected(conn) {
+			return
+		}
+		helpers.Logger.Info("%s: Reconnected to RabbitMQ", s.name)
+	}
+}
+
+// Reconnect drops the current connection, which is then reestablished by
+// the supervisor.
+func (s *Supervisor) Reconnect() {
+	s.disconnected()
+
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+// Wait blocks till the supervisor is connected, or has been closed.
+func (s *Supervisor) Wait() {
+	s.mu.Lock()
+	ready := s.ready
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+	case <-s.done:
+	}
+}
+
+func (s *Supervisor) State() State {
+	return State(atomic.LoadInt32(s.state))
+}
+
+// Done returns a channel that's closed when the supervisor is closed
+func (s *Supervisor) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close closes the connection, and stops reconnecting.
+func (s *Supervisor) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if State(atomic.SwapInt32(s.state, int32(Closed))) == Closed {
+		return nil
+	}
+	close(s.done)
+
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
@@ -4,15 +4,19 @@ import (
 	"errors"
 	"net"
 	"net/rpc"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/TF2Stadium/Helen/models/gameserver"
 	rpcpackage "github.com/TF2Stadium/Helen/models/rpc"
 	"github.com/TF2Stadium/Pauling/config"
+	"github.com/TF2Stadium/Pauling/database"
 	"github.com/TF2Stadium/Pauling/helpers"
+	"github.com/TF2Stadium/Pauling/mq"
 	"github.com/TF2Stadium/Pauling/server"
-	"github.com/TF2Stadium/PlayerStatsScraper/steamid"
+	"github.com/TF2Stadium/Pauling/server/archive"
+	"github.com/TF2Stadium/Pauling/server/stats"
 	rconwrapper "github.com/TF2Stadium/TF2RconWrapper"
 	"github.com/TF2Stadium/rcon"
 	"github.com/streadway/amqp"
@@ -22,16 +26,54 @@ import (
 type Pauling struct{}
 type Noreply struct{}
 
+var (
+	supervisor *mq.Supervisor
+	stopping   = new(int32)
+
+	ErrShuttingDown = errors.New("Pauling is shutting down.")
+)
+
+// StartRPC serves RPC calls on the RPC queue, reconnecting to RabbitMQ if the
+// connection is lost. It blocks till the connection is closed for good.
 func StartRPC(url string) {
-	conn, err := amqp.Dial(url)
-	if err != nil {
+	rpc.Register(new(Pauling))
+
+	supervisor = mq.NewSupervisor("rpc", url, func(conn *amqp.Connection) error {
+		serverCodec, err := amqprpc.NewServerCodec(conn, config.Constants.RPCQueue, amqprpc.JSONCodec{})
+		if err != nil {
+			return err
+		}
+
+		go rpc.ServeCodec(serverCodec)
+		return nil
+	})
+
+	if err := supervisor.Start(); err != nil {
 		helpers.Logger.Fatal(err)
 	}
 
-	serverCodec, err := amqprpc.NewServerCodec(conn, config.Constants.RPCQueue, amqprpc.JSONCodec{})
-	pauling := new(Pauling)
-	rpc.Register(pauling)
-	rpc.ServeCodec(serverCodec)
+	<-supervisor.Done()
+}
+
+// StopAccepting makes SetupServer fail, so that no new servers are set up
+// while shutting down
+func StopAccepting() {
+	atomic.StoreInt32(stopping, 1)
+}
+
+// Close closes the connection RPC calls are served on
+func Close() {
+	if supervisor != nil {
+		supervisor.Close()
+	}
+}
+
+// State returns the state of the connection RPC calls are served on
+func State() mq.State {
+	if supervisor == nil {
+		return mq.Disconnected
+	}
+	return supervisor.State()
 }
 
 func (Pauling) VerifyInfo(info *gameserver.ServerRecord, _ *struct{}) error {
@@ -68,6 +110,10 @@ func (Pauling) VerifyInfo(info *gameserver.ServerRecord, _ *struct{}) error {
 }
 
 func (Pauling) SetupServer(args *rpcpackage.Args, _ *struct{}) error {
+	if atomic.LoadInt32(stopping) == 1 {
+		return ErrShuttingDown
+	}
+
 	s := server.NewServer()
 	s.LobbyId = args.Id
 	s.Info = args.Info
@@ -85,6 +131,9 @@ func (Pauling) SetupServer(args *rpcpackage.Args, _ *struct{}) error {
 	go s.StartVerifier(time.NewTicker(time.Second * 10))
 
 	server.SetServer(args.Id, s)
+	if err := server.SaveServer(s); err != nil {
+		helpers.Logger.Errorf("#%d: Couldn't save server state: %v", args.Id, err)
+	}
 	return nil
 }
 
@@ -104,9 +153,7 @@ func (Pauling) End(args *rpcpackage.Args, nop *Noreply) error {
 		return err
 	}
 
-	server.DeleteServer(s.LobbyId)
-	s.StopListening()
-
+	s.End()
 	return nil
 }
 
@@ -116,8 +163,8 @@ func (Pauling) DisallowPlayer(args *rpcpackage.Args, nop *Noreply) error {
 		return err
 	}
 
-	steamID, _ := steamid.CommIdToSteamId(args.SteamId) //legacy steam id
-	server.ResetReportCount(steamID, args.Id)
+	// votes are kept by community ID
+	server.CancelReports(args.SteamId, args.Id)
 
 	err = s.KickPlayer(args.SteamId, "[tf2stadium.com] You have been replaced.")
 	if err != nil {
@@ -146,3 +193,66 @@ func (Pauling) Exists(lobbyID uint, reply *bool) error {
 
 	return nil
 }
+
+func (Pauling) GetStats(lobbyID uint, reply *stats.Scoreboard) error {
+	board, err := server.GetStats(lobbyID)
+	if err != nil {
+		return err
+	}
+
+	*reply = board
+	return nil
+}
+
+type LogReply struct {
+	Meta archive.Meta
+	Logs string
+}
+
+func (Pauling) GetLog(lobbyID uint, reply *LogReply) error {
+	meta, logs, err := server.Archive.Get(lobbyID)
+	if err != nil {
+		return err
+	}
+
+	reply.Meta = meta
+	reply.Logs = string(logs)
+	return nil
+}
+
+// ListLogs returns the metadata for archived logs, latest first. A limit of
+// 0 returns all logs.
+func (Pauling) ListLogs(limit int, reply *[]archive.Meta) error {
+	metas, err := server.Archive.List()
+	if err != nil {
+		return err
+	}
+
+	if limit > 0 && len(metas) > limit {
+		metas = metas[:limit]
+	}
+	*reply = metas
+	return nil
+}
+
+func (Pauling) GetDemos(lobbyID uint, reply *server.DemoInfo) error {
+	info, err := server.GetDemos(lobbyID)
+	if err != nil {
+		return err
+	}
+
+	*reply = info
+	return nil
+}
+
+// GetReportHistory returns the !rep votes in the lobby args.Id, and/or with
+// the player args.SteamId as the voter or target, latest first.
+func (Pauling) GetReportHistory(args *rpcpackage.Args, reply *[]database.VoteRecord) error {
+	records, err := server.GetReportHistory(args.Id, args.SteamId)
+	if err != nil {
+		return err
+	}
+
+	*reply = records
+	return nil
+}
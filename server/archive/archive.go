@@ -0,0 +1,181 @@
+// Package archive stores compressed match logs on disk, along with metadata
+// about the lobby they're from.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("No logs archived for this lobby.")
+
+type Meta struct {
+	LobbyID uint
+	Map     string
+	Server  string // server address
+	Start   time.Time
+	End     time.Time
+	LogsID  int   // logs.tf ID, 0 if the logs weren't uploaded
+	Size    int64 // uncompressed size in bytes
+}
+
+// Archive stores logs in a directory, as <lobby id>.log.gz with metadata in
+// <lobby id>.json
+type Archive struct {
+	dir       string
+	retention time.Duration
+}
+
+// New returns an archive storing logs in dir, creating it if needed. Logs
+// older than retention are deleted by Prune, a retention of 0 keeps logs
+// forever.
+func New(dir string, retention time.Duration) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &Archive{dir: dir, retention: retention}, nil
+}
+
+func (a *Archive) path(lobbyID uint, ext string) string {
+	return filepath.Join(a.dir, fmt.Sprintf("%d.%s", lobbyID, ext))
+}
+
+// Store archives the logs for a lobby, replacing existing ones
+func (a *Archive) Store(meta Meta, logs []byte) error {
+	meta.Size = int64(len(logs))
+
+	buf := new(bytes.Buffer)
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(logs); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(a.path(meta.LobbyID, "log.gz"), buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	return a.writeMeta(meta)
+}
+
+func (a *Archive) writeMeta(meta Meta) error {
+	bytes, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(a.path(meta.LobbyID, "json"), bytes, 0644)
+}
+
+// Meta returns the metadata for the given lobby's logs
+func (a *Archive) Meta(lobbyID uint) (Meta, error) {
+	var meta Meta
+
+	bytes, err := ioutil.ReadFile(a.path(lobbyID, "json"))
+	if os.IsNotExist(err) {
+		return meta, ErrNotFound
+	} else if err != nil {
+		return meta, err
+	}
+
+	err = json.Unmarshal(bytes, &meta)
+	return meta, err
+}
+
+// SetLogsID updates the logs.tf ID of an archived log
+func (a *Archive) SetLogsID(lobbyID uint, logsID int) error {
+	meta, err := a.Meta(lobbyID)
+	if err != nil {
+		return err
+	}
+
+	meta.LogsID = logsID
+	return a.writeMeta(meta)
+}
+
+// Get returns the metadata and uncompressed logs for a lobby
+func (a *Archive) Get(lobbyID uint) (Meta, []byte, error) {
+	meta, err := a.Meta(lobbyID)
+	if err != nil {
+		return meta, nil, err
+	}
+
+	f, err := os.Open(a.path(lobbyID, "log.gz"))
+	if err != nil {
+		return meta, nil, err
+	}
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		return meta, nil, err
+	}
+
+	logs, err := ioutil.ReadAll(r)
+	return meta, logs, err
+}
+
+type byEnd []Meta
+
+func (m byEnd) Len() int           { return len(m) }
+func (m byEnd) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+func (m byEnd) Less(i, j int) bool { return m[i].End.After(m[j].End) }
+
+// List returns the metadata of all archived logs, latest first
+func (a *Archive) List() ([]Meta, error) {
+	files, err := filepath.Glob(filepath.Join(a.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var metas []Meta
+	for _, file := range files {
+		var lobbyID uint
+		name := strings.TrimSuffix(filepath.Base(file), ".json")
+		if _, err := fmt.Sscan(name, &lobbyID); err != nil {
+			continue
+		}
+
+		meta, err := a.Meta(lobbyID)
+		if err != nil {
+			return nil, err
+		}
+		metas = append(metas, meta)
+	}
+
+	sort.Sort(byEnd(metas))
+	return metas, nil
+}
+
+// Prune deletes logs older than the retention period
+func (a *Archive) Prune() error {
+	if a.retention == 0 {
+		return nil
+	}
+
+	metas, err := a.List()
+	if err != nil {
+		return err
+	}
+
+	before := time.Now().Add(-a.retention)
+	for _, meta := range metas {
+		if meta.End.Before(before) {
+			os.Remove(a.path(meta.LobbyID, "log.gz"))
+			os.Remove(a.path(meta.LobbyID, "json"))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package archive
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchive(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "pauling-archive")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	a, err := New(dir, time.Hour)
+	assert.NoError(t, err)
+
+	logs := []byte("L 10/18/2016 - 20:01:09: World triggered \"Round_Win\" (winner \"Red\")\n")
+	now := time.Now()
+	assert.NoError(t, a.Store(Meta{LobbyID: 1, Map: "cp_badlands", End: now}, logs))
+	assert.NoError(t, a.Store(Meta{LobbyID: 2, Map: "cp_process_final", End: now.Add(-2 * time.Hour)}, logs))
+
+	meta, data, err := a.Get(1)
+	assert.NoError(t, err)
+	assert.Equal(t, logs, data)
+	assert.Equal(t, "cp_badlands", meta.Map)
+	assert.Equal(t, int64(len(logs)), meta.Size)
+
+	assert.NoError(t, a.SetLogsID(1, 1234))
+	meta, err = a.Meta(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1234, meta.LogsID)
+
+	metas, err := a.List()
+	assert.NoError(t, err)
+	if assert.Len(t, metas, 2) {
+		assert.Equal(t, uint(1), metas[0].LobbyID)
+	}
+
+	assert.NoError(t, a.Prune())
+	_, _, err = a.Get(2)
+	assert.Equal(t, ErrNotFound, err)
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"time"
+
+	"github.com/TF2Stadium/Pauling/config"
+	"github.com/TF2Stadium/Pauling/database"
+	"github.com/TF2Stadium/Pauling/helpers"
+	"github.com/TF2Stadium/PlayerStatsScraper/steamid"
+	"github.com/TF2Stadium/TF2RconWrapper"
+)
+
+// ChatPayload is the payload for playerChat events
+type ChatPayload struct {
+	SteamID   string
+	Team      string // lobby team, "red" or "blu"
+	Text      string
+	TeamOnly  bool // sent to team chat
+	Timestamp time.Time
+}
+
+// lobbyTeamsTTL is how long lobbyTeams caches the lobby's players
+const lobbyTeamsTTL = 30 * time.Second
+
+// chatWindow counts a player's relayed messages in the current minute
+type chatWindow struct {
+	start time.Time
+	count int
+}
+
+// chatAllowed reports whether another message from the player can be relayed
+// without going over limit messages a minute, 0 being no limit
+func (s *Server) chatAllowed(commID string, limit int, now time.Time) bool {
+	if limit <= 0 {
+		return true
+	}
+
+	s.chatMu.Lock()
+	defer s.chatMu.Unlock()
+
+	window, ok := s.chatWindow[commID]
+	if !ok || now.Sub(window.start) >= time.Minute {
+		window = &chatWindow{start: now}
+		s.chatWindow[commID] = window
+	}
+
+	window.count++
+	return window.count <= limit
+}
+
+// lobbyTeams returns the team of every player in the lobby, by community ID.
+// The teams are cached for lobbyTeamsTTL, and until a player connects, since
+// they might be a substitute. The map mustn't be modified.
+func (s *Server) lobbyTeams() (map[string]string, error) {
+	s.teamsMu.Lock()
+	defer s.teamsMu.Unlock()
+
+	if s.teams != nil && time.Since(s.teamsLoaded) < lobbyTeamsTTL {
+		return s.teams, nil
+	}
+
+	players, err := database.GetLobbyPlayers(s.LobbyId, s.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	s.teams = make(map[string]string)
+	for _, player := range players {
+		s.teams[player.SteamID] = player.Team
+	}
+	s.teamsLoaded = time.Now()
+	return s.teams, nil
+}
+
+// forgetLobbyTeams makes the next lobbyTeams call read the teams again
+func (s *Server) forgetLobbyTeams() {
+	s.teamsMu.Lock()
+	s.teams = nil
+	s.teamsMu.Unlock()
+}
+
+// relayChat publishes a chat message from a lobby player as a playerChat
+// event
+func (s *Server) relayChat(data TF2RconWrapper.PlayerData, text string, teamOnly bool) {
+	commID, err := steamid.SteamIdToCommId(data.SteamId)
+	if err != nil {
+		return
+	}
+
+	teams, err := s.lobbyTeams()
+	if err != nil {
+		helpers.Logger.Errorf("#%d: Couldn't get the lobby's players: %v", s.LobbyId, err)
+		return
+	}
+
+	team := teams[commID]
+	if team == "" {
+		// not in the lobby
+		return
+	}
+
+	now := time.Now()
+	if !s.chatAllowed(commID, config.Constants.ChatRateLimit, now) {
+		helpers.Logger.Debugf("#%d: Not relaying chat from %s, over the rate limit", s.LobbyId, commID)
+		return
+	}
+
+	publishEvent(Event{
+		Name:    PlayerChat,
+		LobbyID: s.LobbyId,
+		SteamID: commID,
+		Payload: &ChatPayload{
+			SteamID:   commID,
+			Team:      team,
+			Text:      text,
+			TeamOnly:  teamOnly,
+			Timestamp: now.UTC(),
+		}})
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatRateLimit(t *testing.T) {
+	t.Parallel()
+	s := NewServer()
+	now := time.Now()
+
+	assert.True(t, s.chatAllowed("1", 2, now))
+	assert.True(t, s.chatAllowed("1", 2, now))
+	assert.False(t, s.chatAllowed("1", 2, now))
+	assert.True(t, s.chatAllowed("2", 2, now))
+	assert.True(t, s.chatAllowed("1", 2, now.Add(time.Minute)))
+
+	// no limit
+	for i := 0; i < 10; i++ {
+		assert.True(t, s.chatAllowed("3", 0, now))
+	}
+}
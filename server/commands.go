@@ -0,0 +1,280 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TF2Stadium/Pauling/config"
+	"github.com/TF2Stadium/Pauling/database"
+	"github.com/TF2Stadium/PlayerStatsScraper/steamid"
+	"github.com/TF2Stadium/TF2RconWrapper"
+)
+
+// Permission says who can run a chat command. Admins can run every command.
+type Permission int
+
+const (
+	AnyPlayer   Permission = iota // players in the lobby, and its leader
+	TeamPlayer                    // players on a lobby team
+	LobbyLeader                   // the lobby's leader
+	Admin                         // SteamIDs in PAULING_ADMIN_STEAMIDS
+)
+
+// CommandContext is a single invocation of a chat command
+type CommandContext struct {
+	Player   TF2RconWrapper.PlayerData
+	CommID   string   // community ID of the player
+	Args     []string // whitespace separated words after the command
+	Team     string   // the player's lobby team, "red", "blu" or ""
+	TeamChat bool     // true if sent to team chat
+}
+
+// Command is a chat command, run by saying !<name> or !<alias> in chat.
+// Commands work the same in global and team chat, so that players can use
+// !rep and !sub without the other team seeing.
+type Command struct {
+	Name    string
+	Aliases []string
+	Usage   string // arguments, e.g. "<team> <slot>"
+	Help    string // what the command does, shown by !help
+
+	// Parse validates the arguments before the command is run. If it
+	// returns an error, the error and the usage are said in chat. If Parse
+	// is nil, only MinArgs is checked.
+	Parse   func(s *Server, ctx *CommandContext) error
+	MinArgs int
+
+	Cooldown   time.Duration // per player
+	Permission Permission
+
+	Run func(s *Server, ctx *CommandContext)
+}
+
+var (
+	commandsMu sync.RWMutex
+	commands   = make(map[string]*Command) // by name and alias
+	cmdList    []*Command
+)
+
+// RegisterCommand makes cmd available on every server
+func RegisterCommand(cmd *Command) {
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+
+	commands[cmd.Name] = cmd
+	for _, alias := range cmd.Aliases {
+		commands[alias] = cmd
+	}
+	cmdList = append(cmdList, cmd)
+}
+
+func lookupCommand(name string) *Command {
+	commandsMu.RLock()
+	defer commandsMu.RUnlock()
+
+	return commands[strings.ToLower(name)]
+}
+
+// parseCommand splits a chat message into the command name and arguments,
+// returning an empty name for messages which aren't commands.
+func parseCommand(text string) (string, []string) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "!") {
+		return "", nil
+	}
+
+	return strings.ToLower(fields[0][1:]), fields[1:]
+}
+
+func (cmd *Command) usage() string {
+	if cmd.Usage == "" {
+		return "!" + cmd.Name
+	}
+	return "!" + cmd.Name + " " + cmd.Usage
+}
+
+func isAdmin(commID string) bool {
+	for _, admin := range config.Constants.AdminSteamIDs {
+		if admin == commID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) allowed(cmd *Command, ctx *CommandContext) bool {
+	if isAdmin(ctx.CommID) {
+		return true
+	}
+
+	switch cmd.Permission {
+	case AnyPlayer:
+		return ctx.Team != "" || database.GetLobbyLeader(s.LobbyId) == ctx.CommID
+	case TeamPlayer:
+		return ctx.Team != ""
+	case LobbyLeader:
+		return database.GetLobbyLeader(s.LobbyId) == ctx.CommID
+	}
+	return false
+}
+
+// onCooldown reports whether the player ran the command too recently, and
+// marks it as run otherwise
+func (s *Server) onCooldown(cmd *Command, commID string) bool {
+	if cmd.Cooldown == 0 {
+		return false
+	}
+
+	key := cmd.Name + commID
+
+	s.cmdMu.Lock()
+	defer s.cmdMu.Unlock()
+
+	if last, ok := s.lastRun[key]; ok && time.Since(last) < cmd.Cooldown {
+		return true
+	}
+	s.lastRun[key] = time.Now()
+	return false
+}
+
+// runCommand runs the command in text, if it's one. teamChat is true if text
+// was said in team chat.
+func (s *Server) runCommand(data TF2RconWrapper.PlayerData, text string, teamChat bool) {
+	name, args := parseCommand(text)
+	if name == "" {
+		return
+	}
+
+	cmd := lookupCommand(name)
+	if cmd == nil {
+		return
+	}
+
+	commID, _ := steamid.SteamIdToCommId(data.SteamId)
+	ctx := &CommandContext{
+		Player:   data,
+		CommID:   commID,
+		Args:     args,
+		Team:     database.GetTeam(s.LobbyId, s.Type, commID),
+		TeamChat: teamChat,
+	}
+
+	if !s.allowed(cmd, ctx) {
+		switch cmd.Permission {
+		case AnyPlayer, TeamPlayer:
+			s.reply(ctx.Player, fmt.Sprintf("!%s: You aren't in the lobby.", cmd.Name))
+		default:
+			s.reply(ctx.Player, fmt.Sprintf("You aren't allowed to use !%s.", cmd.Name))
+		}
+		return
+	}
+
+	if cmd.Parse != nil {
+		if err := cmd.Parse(s, ctx); err != nil {
+			s.reply(ctx.Player, fmt.Sprintf("%s. Usage: %s", err.Error(), cmd.usage()))
+			return
+		}
+	} else if len(args) < cmd.MinArgs {
+		s.reply(ctx.Player, "Usage: "+cmd.usage())
+		return
+	}
+
+	if s.onCooldown(cmd, commID) {
+		s.reply(ctx.Player, fmt.Sprintf("!%s: Please wait before using it again.", cmd.Name))
+		return
+	}
+
+	cmd.Run(s, ctx)
+}
+
+// commandHelp returns the help of cmd in lang, or in English if it isn't
+// translated
+func commandHelp(cmd *Command, lang string) string {
+	if help, ok := messages[lang]["help "+cmd.Name]; ok {
+		return help
+	}
+	return cmd.Help
+}
+
+// helpCommand describes a single command, or lists all commands
+func helpCommand(s *Server, ctx *CommandContext) {
+	if len(ctx.Args) != 0 {
+		cmd := lookupCommand(strings.TrimPrefix(ctx.Args[0], "!"))
+		if cmd == nil {
+			s.reply(ctx.Player, "!help: Unknown command "+ctx.Args[0])
+			return
+		}
+
+		s.reply(ctx.Player, fmt.Sprintf("%s - %s", cmd.usage(), commandHelp(cmd, config.Constants.Language)))
+		return
+	}
+
+	commandsMu.RLock()
+	var names []string
+	for _, cmd := range cmdList {
+		names = append(names, "!"+cmd.Name)
+	}
+	commandsMu.RUnlock()
+	sort.Strings(names)
+
+	s.reply(ctx.Player, fmt.Sprintf("Commands: %s. Use !help <command> for details.", strings.Join(names, ", ")))
+}
+
+func subCommand(s *Server, ctx *CommandContext) {
+	if len(ctx.Args) != 0 {
+		// If they tried to use !sub with an argument, they
+		// probably meant to !rep
+		s.reply(ctx.Player, "!sub is for replacing yourself, !rep reports others.")
+		return
+	}
+
+	publishEvent(Event{
+		Name:    PlayerSubstituted,
+		LobbyID: s.LobbyId,
+		SteamID: ctx.CommID,
+		Self:    true})
+
+	say := fmt.Sprintf("Reporting player %s (%s)",
+		ctx.Player.Username, ctx.Player.SteamId)
+	s.replyTeam(ctx.Team, say)
+}
+
+func init() {
+	RegisterCommand(&Command{
+		Name:       "rep",
+		Aliases:    []string{"report"},
+		Usage:      "our/their/red/blu <slot> | <name>",
+		Help:       "vote to replace a player",
+		Permission: TeamPlayer,
+		Run: func(s *Server, ctx *CommandContext) {
+			s.report(ctx.Player, ctx.Args)
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:       "sub",
+		Help:       "get yourself replaced",
+		Permission: TeamPlayer,
+		Run:        subCommand,
+	})
+
+	RegisterCommand(&Command{
+		Name:     "soapoff",
+		Help:     "disable SOAP DM",
+		Cooldown: 10 * time.Second,
+		Run: func(s *Server, _ *CommandContext) {
+			ExecFile("soap_off.cfg", s.rcon)
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:     "help",
+		Usage:    "[command]",
+		Help:     "list commands, or describe one",
+		Cooldown: 5 * time.Second,
+		Run:      helpCommand,
+	})
+}
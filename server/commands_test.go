@@ -0,0 +1,26 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCommand(t *testing.T) {
+	t.Parallel()
+	name, args := parseCommand("!REP  red   scout1 ")
+	assert.Equal(t, "rep", name)
+	assert.Equal(t, []string{"red", "scout1"}, args)
+
+	name, _ = parseCommand("gg rep")
+	assert.Empty(t, name)
+	name, _ = parseCommand("")
+	assert.Empty(t, name)
+}
+
+func TestLookupCommand(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "rep", lookupCommand("report").Name)
+	assert.Equal(t, "help", lookupCommand("Help").Name)
+	assert.Nil(t, lookupCommand("nope"))
+}
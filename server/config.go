@@ -10,7 +10,6 @@ import (
 	"time"
 
 	"github.com/TF2Stadium/Helen/models/lobby/format"
-	tf2rcon "github.com/TF2Stadium/TF2RconWrapper"
 )
 
 var formatMap = map[format.Format]string{
@@ -47,6 +46,11 @@ func ConfigName(mapName string, lobbyType format.Format, ruleset string) (string
 	return file, nil
 }
 
+// FormatName returns the name used for lobbyType in config file names
+func FormatName(lobbyType format.Format) string {
+	return formatMap[lobbyType]
+}
+
 func FormatConfigName(lobbyType format.Format) string {
 	return fmt.Sprintf("formats/%s.cfg", formatMap[lobbyType])
 }
@@ -62,7 +66,7 @@ func stripComments(s string) string {
 
 //Execute file located at path on rcon
 //TODO: Shouldn't this be in TF2RconWrapper?
-func ExecFile(path string, rcon *tf2rcon.TF2RconConnection) error {
+func ExecFile(path string, rcon *rconConn) error {
 	configPath, _ := filepath.Abs("./configs/")
 	data, err := ioutil.ReadFile(configPath + "/" + path)
 	if err != nil {
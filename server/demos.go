@@ -0,0 +1,189 @@
+package server
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/TF2Stadium/Pauling/helpers"
+)
+
+type Demo struct {
+	Filename string
+	Started  time.Time
+	Stopped  time.Time // zero if still recording
+}
+
+// DemoInfo has the STV demos recorded for a lobby
+type DemoInfo struct {
+	Demos []Demo
+	URL   string // where the demos can be downloaded, if announced by the server
+}
+
+// lobbyDemos is the stored DemoInfo for a lobby
+type lobbyDemos struct {
+	LobbyID uint   `gorm:"primary_key"`
+	Info    string // JSON encoded DemoInfo
+}
+
+var (
+	rDemoURL      = regexp.MustCompile(`https?://[^\s"]+`)
+	rTVRecord     = regexp.MustCompile(`^tv_record\s+"?([^"\s;]+)`)
+	rTVStatusDemo = regexp.MustCompile(`Recording to "?([^"\s,]+)`)
+)
+
+// how often trackDemos runs tv_status
+const demoPollPeriod = 15 * time.Second
+
+func demoFilename(name string) string {
+	if !strings.HasSuffix(name, ".dem") {
+		name += ".dem"
+	}
+	return name
+}
+
+// parseDemoCommand looks for demo recording commands sent over rcon
+func (s *Server) parseDemoCommand(command string) {
+	command = strings.TrimSpace(command)
+
+	switch {
+	case rTVRecord.MatchString(command):
+		s.demoStarted(rTVRecord.FindStringSubmatch(command)[1])
+	case strings.HasPrefix(command, "tv_stoprecord"):
+		s.demoStopped("")
+	}
+}
+
+// trackDemos checks which demo SourceTV is recording with tv_status, till the
+// server stops listening. SourceTV doesn't write its messages to the logs, so
+// this catches demos recorded without going through rcon, like tv_autorecord.
+func (s *Server) trackDemos() {
+	ticker := time.NewTicker(demoPollPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			status, err := s.rcon.Query("tv_status")
+			if err != nil {
+				continue
+			}
+
+			if name := recordingDemo(status); name != "" {
+				s.demoStarted(name)
+			} else if s.isRecording() {
+				s.demoStopped("")
+			}
+		}
+	}
+}
+
+// recordingDemo returns the demo being recorded according to the output of
+// tv_status, or "" if SourceTV isn't recording
+func recordingDemo(status string) string {
+	match := rTVStatusDemo.FindStringSubmatch(status)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// isRecording reports whether the last demo started hasn't stopped yet
+func (s *Server) isRecording() bool {
+	s.demoMu.Lock()
+	defer s.demoMu.Unlock()
+
+	n := len(s.demos.Demos)
+	return n != 0 && s.demos.Demos[n-1].Stopped.IsZero()
+}
+
+func (s *Server) demoStarted(name string) {
+	name = demoFilename(name)
+
+	s.demoMu.Lock()
+	for _, demo := range s.demos.Demos {
+		if demo.Filename == name {
+			s.demoMu.Unlock()
+			return
+		}
+	}
+	s.demos.Demos = append(s.demos.Demos, Demo{Filename: name, Started: time.Now()})
+	s.demoMu.Unlock()
+
+	helpers.Logger.Debugf("#%d: Recording demo %s", s.LobbyId, name)
+	s.saveDemos()
+}
+
+// demoStopped marks the demo with the given name as stopped, or the last one
+// if name is empty
+func (s *Server) demoStopped(name string) {
+	if name != "" {
+		name = demoFilename(name)
+	}
+
+	s.demoMu.Lock()
+	for i := len(s.demos.Demos) - 1; i >= 0; i-- {
+		demo := &s.demos.Demos[i]
+		if (name == "" || demo.Filename == name) && demo.Stopped.IsZero() {
+			demo.Stopped = time.Now()
+			break
+		}
+	}
+	s.demoMu.Unlock()
+
+	s.saveDemos()
+}
+
+func (s *Server) setDemoURL(text string) {
+	url := rDemoURL.FindString(text)
+	if url == "" {
+		return
+	}
+
+	s.demoMu.Lock()
+	s.demos.URL = url
+	s.demoMu.Unlock()
+
+	s.saveDemos()
+}
+
+// Demos returns the demos recorded for the lobby so far
+func (s *Server) Demos() DemoInfo {
+	s.demoMu.Lock()
+	defer s.demoMu.Unlock()
+
+	info := s.demos
+	info.Demos = append([]Demo(nil), s.demos.Demos...)
+	return info
+}
+
+func (s *Server) saveDemos() {
+	bytes, _ := json.Marshal(s.Demos())
+
+	db.Table("lobby_demos").Where("lobby_id = ?", s.LobbyId).Delete(&lobbyDemos{})
+	err := db.Table("lobby_demos").Create(&lobbyDemos{LobbyID: s.LobbyId, Info: string(bytes)}).Error
+	if err != nil {
+		helpers.Logger.Errorf("#%d: Couldn't save demos: %v", s.LobbyId, err)
+	}
+}
+
+// GetDemos returns the demos recorded for a lobby, which may have ended
+func GetDemos(lobbyID uint) (DemoInfo, error) {
+	var info DemoInfo
+
+	if s, err := GetServer(lobbyID); err == nil {
+		return s.Demos(), nil
+	}
+
+	var saved lobbyDemos
+	err := db.Table("lobby_demos").Where("lobby_id = ?", lobbyID).First(&saved).Error
+	if err != nil {
+		return info, ErrNoDemos
+	}
+
+	err = json.Unmarshal([]byte(saved.Info), &info)
+	return info, err
+}
@@ -0,0 +1,20 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordingDemo(t *testing.T) {
+	t.Parallel()
+	status := `--- SourceTV Status ---
+SourceTV online, Port 27020, Mode Game.
+Local IP 192.168.1.2:27020, KB/sec In 0.0, Out 12.3
+Local Slots 12, Spectators 0, Proxies 0
+Total Slots 12, Spectators 0, Proxies 0
+Recording to "auto-20160512-1937-cp_badlands.dem", length 302.1 sec.`
+	assert.Equal(t, "auto-20160512-1937-cp_badlands.dem", recordingDemo(status))
+
+	assert.Equal(t, "", recordingDemo("--- SourceTV Status ---\nSourceTV not active."))
+}
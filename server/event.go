@@ -1,21 +1,67 @@
 package server
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/TF2Stadium/Pauling/config"
 	"github.com/TF2Stadium/Pauling/helpers"
+	"github.com/TF2Stadium/Pauling/mq"
 	"github.com/TF2Stadium/TF2RconWrapper"
 	"github.com/streadway/amqp"
 )
 
 var (
-	conn    *amqp.Connection
-	queue   amqp.Queue
-	channel *amqp.Channel
+	eventsMQ *mq.Supervisor
+
+	// the channel events are published on, replaced by setupChannel every
+	// time the supervisor reconnects
+	channelMu     sync.Mutex
+	eventsChannel *eventChannel
 )
 
-//Mirrored across github.com/TF2Stadium/Helen/event
+// eventChannel is an AMQP channel with publisher confirms enabled
+type eventChannel struct {
+	channel  *amqp.Channel
+	queue    amqp.Queue
+	confirms chan amqp.Confirmation
+
+	mu        sync.Mutex // held while publishing and waiting for the confirm
+	published uint64     // delivery tag of the last event published
+}
+
+// outboxEvent is an event waiting to be sent to the event sinks. Events are
+// sent in ID order, so each lobby's events arrive in the order they were
+// emitted. Sent is set once every sink has the event.
+type outboxEvent struct {
+	ID        uint
+	LobbyID   uint
+	Body      string // JSON encoded Event
+	CreatedAt time.Time
+	Sent      bool
+	SentAt    time.Time
+}
+
+// EventSchemaVersion is the version of the event envelope. Version 1 events
+// only had the legacy fields.
+const EventSchemaVersion = 2
+
+// Event is the envelope for everything sent to event sinks.
+//
+// The legacy fields (SteamID, LogsID, Players and Self) are mirrored across
+// github.com/TF2Stadium/Helen/event, and are still filled for Helen. New
+// consumers should use Payload, which has a type depending on Name.
+//
+// Events reach every sink in the order they were emitted, so a lobby's
+// events are never reordered. Delivery is at least once rather than exactly
+// once: an event whose publish failed or wasn't confirmed in time is sent
+// again, even if the broker got it, and Pauling can't tell the difference.
+// Consumers have to ignore events with an EventID they've already seen.
 type Event struct {
 	Name    string
 	SteamID string
@@ -25,6 +71,111 @@ type Event struct {
 	Players []TF2RconWrapper.Player
 
 	Self bool // true if player has repped themselves
+
+	EventID       string    // unique, used by consumers to dedupe events
+	ServerID      uint      // ID of the lobby's gameserver.ServerRecord
+	EmittedAt     time.Time // UTC
+	SchemaVersion int
+	Payload       interface{} `json:",omitempty"`
+}
+
+// PlayerPayload is the payload for playerConn and playerDisc events
+type PlayerPayload struct {
+	SteamID string
+}
+
+// SubstitutePayload is the payload for playerSub events
+type SubstitutePayload struct {
+	SteamID string
+	Self    bool // true if player has repped themselves
+}
+
+// MatchEndedPayload is the payload for matchEnded events
+type MatchEndedPayload struct {
+	LogsID int // 0 if the logs couldn't be uploaded
+	Demos  DemoInfo
+}
+
+// ReservationOverPayload is the payload for reservationOver events
+type ReservationOverPayload struct {
+	Demos DemoInfo
+}
+
+// PlayersListPayload is the payload for playersList events
+type PlayersListPayload struct {
+	Players []TF2RconWrapper.Player
+}
+
+// newPayload returns a new payload of the type used by events named name,
+// or nil for events without a payload.
+func newPayload(name string) interface{} {
+	switch name {
+	case PlayerConnected, PlayerDisconnected:
+		return &PlayerPayload{}
+	case PlayerSubstituted:
+		return &SubstitutePayload{}
+	case MatchEnded:
+		return &MatchEndedPayload{}
+	case ReservationOver:
+		return &ReservationOverPayload{}
+	case PlayersList:
+		return &PlayersListPayload{}
+	case StatsUpdate:
+		return &StatsPayload{}
+	case LogsUploaded:
+		return &LogsUploadedPayload{}
+	case PlayerKicked:
+		return &KickPayload{}
+	case PlayerChat:
+		return &ChatPayload{}
+	case MatchPaused:
+		return &PausePayload{}
+	case MatchUnpaused:
+		return &UnpausePayload{}
+	}
+	return nil
+}
+
+// legacyPayload builds the payload from the legacy fields
+func (e Event) legacyPayload() interface{} {
+	switch e.Name {
+	case PlayerConnected, PlayerDisconnected:
+		return &PlayerPayload{SteamID: e.SteamID}
+	case PlayerSubstituted:
+		return &SubstitutePayload{SteamID: e.SteamID, Self: e.Self}
+	case MatchEnded:
+		return &MatchEndedPayload{LogsID: e.LogsID}
+	case PlayersList:
+		return &PlayersListPayload{Players: e.Players}
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes the payload into the type used for the event's name
+func (e *Event) UnmarshalJSON(data []byte) error {
+	type event Event
+	raw := struct {
+		*event
+		Payload json.RawMessage
+	}{event: (*event)(e)}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	e.Payload = newPayload(e.Name)
+	if e.Payload == nil || len(raw.Payload) == 0 || string(raw.Payload) == "null" {
+		e.Payload = nil
+		return nil
+	}
+
+	return json.Unmarshal(raw.Payload, e.Payload)
+}
+
+func newEventID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
 }
 
 const (
@@ -39,46 +190,204 @@ const (
 	ReservationOver        string = "reservationOver"
 
 	PlayersList string = "playersList"
+	StatsUpdate string = "statsUpdate"
+
+	LogsUploaded string = "logsUploaded" // logs.tf upload succeeded after MatchEnded
+	PlayerKicked string = "playerKicked" // kicked by !kick
+
+	MatchPaused   string = "matchPaused"
+	MatchUnpaused string = "matchUnpaused"
 )
 
-func connectMQ() {
-	var err error
+// amqpSink publishes events to the RabbitMQ queue Helen consumes
+type amqpSink struct{}
 
-	conn, err = amqp.Dial(config.Constants.RabbitMQURL)
-	if err != nil {
+func connectMQ() amqpSink {
+	eventsMQ = mq.NewSupervisor("events", config.Constants.RabbitMQURL, setupChannel)
+	if err := eventsMQ.Start(); err != nil {
 		helpers.Logger.Fatalf("Failed to connect to RabbitMQ - %s", err.Error())
 	}
 
-	channel, err = conn.Channel()
+	helpers.Logger.Info("Sending events on queue %s on %s", config.Constants.RabbitMQQueue, config.Constants.RabbitMQURL)
+	return amqpSink{}
+}
+
+func (amqpSink) Name() string { return "amqp" }
+
+// setupChannel opens the channel events are published on, called by the
+// supervisor every time it (re)connects to RabbitMQ
+func setupChannel(conn *amqp.Connection) error {
+	channel, err := conn.Channel()
 	if err != nil {
-		helpers.Logger.Fatalf("Failed to open a channel - %s", err.Error())
+		return fmt.Errorf("Failed to open a channel - %s", err.Error())
 	}
 
-	queue, err = channel.QueueDeclare(
-		config.Constants.RabbitMQQueue, // name
-		false, // durable
-		false, // delete when unused
-		false, // exclusive
-		false, // no-wait
-		nil,   // arguments
+	queue, err := channel.QueueDeclare(
+		config.Constants.RabbitMQQueue,   // name
+		config.Constants.RabbitMQDurable, // durable
+		false,                            // delete when unused
+		false,                            // exclusive
+		false,                            // no-wait
+		nil,                              // arguments
 	)
-
 	if err != nil {
-		helpers.Logger.Fatalf("Failed to declare a queue - %s", err.Error())
+		return fmt.Errorf("Failed to declare a queue - %s", err.Error())
 	}
 
-	helpers.Logger.Info("Sending events on queue %s on %s", config.Constants.RabbitMQQueue, config.Constants.RabbitMQURL)
+	if err = channel.Confirm(false); err != nil {
+		return fmt.Errorf("Failed to enable publisher confirms - %s", err.Error())
+	}
+	confirms := channel.NotifyPublish(make(chan amqp.Confirmation, 1))
+
+	channelMu.Lock()
+	eventsChannel = &eventChannel{channel: channel, queue: queue, confirms: confirms}
+	channelMu.Unlock()
+	return nil
+}
+
+// EventsState returns the state of the connection events are published on
+func EventsState() mq.State {
+	if eventsMQ == nil {
+		return mq.Disconnected
+	}
+	return eventsMQ.State()
 }
 
+// publishEvent stores e in the outbox, from where it'll be sent to every
+// event sink
 func publishEvent(e Event) {
-	bytes, _ := json.Marshal(e)
-	channel.Publish(
+	e.EventID = newEventID()
+	e.EmittedAt = time.Now().UTC()
+	e.SchemaVersion = EventSchemaVersion
+	if e.Payload == nil {
+		e.Payload = e.legacyPayload()
+	}
+	if s, err := GetServer(e.LobbyID); err == nil {
+		e.ServerID = s.Info.ID
+	}
+
+	bytes, err := json.Marshal(e)
+	if err != nil {
+		helpers.Logger.Errorf("#%d: Couldn't encode %s event: %v", e.LobbyID, e.Name, err)
+		return
+	}
+
+	err = db.Table("outbox_events").Create(&outboxEvent{LobbyID: e.LobbyID, Body: string(bytes)}).Error
+	if err != nil {
+		helpers.Logger.Errorf("#%d: Couldn't store %s event: %v", e.LobbyID, e.Name, err)
+		return
+	}
+
+	notifySinks()
+}
+
+func publishOutbox() {
+	ticker := time.NewTicker(10 * time.Second)
+
+	for range ticker.C {
+		markSent()
+		pruneOutbox()
+	}
+}
+
+// flushOutbox sends all pending events to every sink, giving up on sinks
+// which take longer than timeout. Events that couldn't be sent to a sink are
+// left in the outbox, to be sent after restarting.
+func flushOutbox(timeout time.Duration) {
+	sinksMu.RLock()
+	workers := sinks
+	sinksMu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w *sinkWorker) {
+			if err := w.flush(); err != nil {
+				helpers.Logger.Errorf("Couldn't send pending events to %s: %v", w.sink.Name(), err)
+			}
+			wg.Done()
+		}(w)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		helpers.Logger.Error("Timed out while sending pending events")
+	}
+
+	markSent()
+}
+
+func (amqpSink) Publish(_ Event, body []byte) error {
+	eventsMQ.Wait()
+	if eventsMQ.State() == mq.Closed {
+		return ErrSinkClosed
+	}
+
+	err := sendEvent(body)
+	if err != nil {
+		eventsMQ.Reconnect()
+	}
+	return err
+}
+
+func sendEvent(body []byte) error {
+	channelMu.Lock()
+	events := eventsChannel
+	channelMu.Unlock()
+
+	events.mu.Lock()
+	defer events.mu.Unlock()
+
+	err := events.channel.Publish(
 		"",
-		queue.Name,
+		events.queue.Name,
 		false,
 		false,
 		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        bytes,
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Body:         body,
 		})
+	if err != nil {
+		return err
+	}
+	// delivery tags count the messages published on the channel, from 1
+	events.published++
+	tag := events.published
+
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case confirm, ok := <-events.confirms:
+			if !ok {
+				return errors.New("channel closed before the event was confirmed")
+			}
+			if confirm.DeliveryTag < tag {
+				// late confirm for an earlier event which timed out
+				continue
+			}
+			if !confirm.Ack {
+				return errors.New("event wasn't acknowledged by the broker")
+			}
+			return nil
+		case <-timeout:
+			return errors.New("timed out waiting for confirmation")
+		}
+	}
+}
+
+// pruneOutbox deletes sent events older than the configured retention
+func pruneOutbox() {
+	before := time.Now().Add(-config.Constants.OutboxRetention)
+	err := db.Table("outbox_events").Where("sent = ? AND sent_at < ?", true, before).Delete(&outboxEvent{}).Error
+	if err != nil {
+		helpers.Logger.Error(err.Error())
+	}
 }
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventJSON(t *testing.T) {
+	t.Parallel()
+	e := Event{
+		Name:          PlayerSubstituted,
+		LobbyID:       3,
+		SteamID:       "76561198011940487",
+		Self:          true,
+		EventID:       newEventID(),
+		SchemaVersion: EventSchemaVersion,
+	}
+	e.Payload = e.legacyPayload()
+
+	bytes, err := json.Marshal(e)
+	assert.NoError(t, err)
+
+	// Helen only knows about the legacy fields
+	var legacy struct {
+		Name    string
+		SteamID string
+		LobbyID uint
+		Self    bool
+	}
+	assert.NoError(t, json.Unmarshal(bytes, &legacy))
+	assert.Equal(t, e.SteamID, legacy.SteamID)
+	assert.True(t, legacy.Self)
+
+	var decoded Event
+	assert.NoError(t, json.Unmarshal(bytes, &decoded))
+	assert.Equal(t, e.EventID, decoded.EventID)
+	assert.Equal(t, &SubstitutePayload{SteamID: e.SteamID, Self: true}, decoded.Payload)
+
+	bytes, _ = json.Marshal(Event{Name: ReservationOver, LobbyID: 3})
+	decoded = Event{}
+	assert.NoError(t, json.Unmarshal(bytes, &decoded))
+	assert.Nil(t, decoded.Payload)
+}
@@ -0,0 +1,313 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/TF2Stadium/Pauling/database"
+	"github.com/TF2Stadium/Pauling/helpers"
+	"github.com/TF2Stadium/PlayerStatsScraper/steamid"
+	"github.com/TF2Stadium/TF2RconWrapper"
+)
+
+// kickVoteWindow is how long a !kick vote stays open after the first vote
+const kickVoteWindow = 2 * time.Minute
+
+// kickReason is shown to kicked players, and to kicked players trying to
+// join again
+const kickReason = "[tf2stadium.com] You have been kicked from the server."
+
+// KickPayload is the payload for playerKicked events
+type KickPayload struct {
+	SteamID string
+	// Team and slot of the player, empty if they weren't in the lobby
+	Team string
+	Slot string
+	// Community ID of the lobby leader or admin who kicked the player, empty
+	// if the player was voted out
+	KickedBy string
+	Votes    int
+}
+
+// kickVote is a running !kick vote against a player, which expires at
+// deadline
+type kickVote struct {
+	req      kickRequest // the first !kick, only the target fields are used
+	voters   map[string]bool
+	deadline time.Time
+	timer    *time.Timer // nil until the vote's server is resumed
+}
+
+// kickRequest is a single !kick
+type kickRequest struct {
+	target string // community ID
+	name   string // shown in chat
+	team   string // target's lobby team and slot, empty if they aren't in it
+	slot   string
+
+	voter     string // community ID
+	voterTeam string
+	instant   bool // voter is an admin or the lobby leader
+}
+
+var (
+	errKickSelf  = errors.New("!kick: Use !sub to replace yourself.")
+	errKickVoter = errors.New("!kick: Only players in the lobby can vote to kick.")
+	errKickTeam  = errors.New("!kick: Only the player's team can vote to kick them.")
+	errKickVoted = errors.New("!kick: You have already voted.")
+)
+
+// kicksNeeded returns the number of votes from the target's teammates
+// needed to kick them, a majority of the rest of the team.
+func kicksNeeded(f format.Format) int {
+	return (format.NumberOfClassesMap[f]-1)/2 + 1
+}
+
+// outsiderKicksNeeded returns the number of votes needed to kick a player
+// who isn't in the lobby, a majority of both teams.
+func outsiderKicksNeeded(f format.Format) int {
+	return format.NumberOfClassesMap[f] + 1
+}
+
+func kickCommand(s *Server, ctx *CommandContext) {
+	req := kickRequest{
+		voter:     ctx.CommID,
+		voterTeam: ctx.Team,
+		instant:   isAdmin(ctx.CommID) || database.GetLobbyLeader(s.LobbyId) == ctx.CommID,
+	}
+
+	if len(ctx.Args) >= 2 && isTeam(strings.ToLower(ctx.Args[0])) {
+		team := s.resolveTeam(ctx.CommID, strings.ToLower(ctx.Args[0]))
+		if team == "" {
+			s.reply(ctx.Player, message("unknownTeam", "!kick"))
+			return
+		}
+
+		slot := resolveSlot(s.Type, strings.ToLower(ctx.Args[1]))
+		if slot == "" {
+			s.replyLines(ctx.Player, slotHelp(message("validSlots", "!kick"), s.Type))
+			return
+		}
+
+		target, err := database.GetSteamIDFromSlot(team, slot, s.LobbyId, s.Type)
+		if err != nil {
+			s.reply(ctx.Player, "!kick: Nobody is in that slot")
+			return
+		}
+
+		req.target, req.team, req.slot = target, team, slot
+		req.name = fmt.Sprintf("%s %s: %s", strings.ToUpper(team), strings.ToUpper(slot), database.GetNameFromSteamID(target))
+	} else {
+		// anyone on the server, by their in-game name
+		player, ok := s.findServerPlayer(ctx.Player, strings.Join(ctx.Args, " "))
+		if !ok {
+			return
+		}
+
+		req.target, _ = steamid.SteamIdToCommId(player.SteamID)
+		req.team, req.slot = database.GetTeamClass(s.LobbyId, s.Type, req.target)
+		req.name = player.Username
+	}
+
+	needed := kicksNeeded(s.Type)
+	if req.team == "" {
+		needed = outsiderKicksNeeded(s.Type)
+	}
+	votes, kick, err := s.castKickVote(req, needed, kickVoteWindow, func() { s.kickVoteExpired(req) })
+	if err != nil {
+		s.reply(ctx.Player, err.Error())
+		return
+	}
+
+	if !req.instant {
+		s.replyVoters(req.team, fmt.Sprintf("Got %d votes for kicking %s (%d needed)", votes, req.name, needed))
+	}
+
+	if kick {
+		kickedBy := ""
+		if req.instant {
+			kickedBy = req.voter
+		}
+		s.kick(req, kickedBy, votes)
+	}
+}
+
+// castKickVote counts the vote for req, returning the votes so far and
+// whether the target should be kicked now. Admins and the lobby leader kick
+// instantly. Lobby players vote against their teammates, or, with both
+// teams, against players who aren't in the lobby. expired is called if the
+// vote doesn't pass in window.
+func (s *Server) castKickVote(req kickRequest, needed int, window time.Duration, expired func()) (int, bool, error) {
+	switch {
+	case req.target == req.voter:
+		return 0, false, errKickSelf
+	case req.instant:
+		return 0, true, nil
+	case req.voterTeam == "":
+		return 0, false, errKickVoter
+	case req.team != "" && req.voterTeam != req.team:
+		return 0, false, errKickTeam
+	}
+
+	s.kickMu.Lock()
+	defer s.kickMu.Unlock()
+
+	vote, ok := s.kickVotes[req.target]
+	if !ok {
+		vote = &kickVote{
+			req:      req,
+			voters:   make(map[string]bool),
+			deadline: time.Now().Add(window),
+		}
+		s.startKickTimer(vote, expired)
+		s.kickVotes[req.target] = vote
+	}
+
+	if vote.voters[req.voter] {
+		return len(vote.voters), false, errKickVoted
+	}
+	vote.voters[req.voter] = true
+	votes := len(vote.voters)
+
+	if votes < needed {
+		return votes, false, nil
+	}
+
+	if vote.timer != nil {
+		vote.timer.Stop()
+	}
+	delete(s.kickVotes, req.target)
+	return votes, true, nil
+}
+
+// startKickTimer forgets the vote and calls expired if the vote is still
+// open at its deadline. s.kickMu has to be held.
+func (s *Server) startKickTimer(vote *kickVote, expired func()) {
+	vote.timer = time.AfterFunc(vote.deadline.Sub(time.Now()), func() {
+		s.kickMu.Lock()
+		current := s.kickVotes[vote.req.target] == vote
+		if current {
+			delete(s.kickVotes, vote.req.target)
+		}
+		s.kickMu.Unlock()
+
+		if current {
+			expired()
+		}
+	})
+}
+
+// kickVoteExpired tells the players voting on req's target that the vote
+// failed
+func (s *Server) kickVoteExpired(req kickRequest) {
+	s.replyVoters(req.team, fmt.Sprintf("Kicking %s failed, couldn't get enough votes in %s.", req.name, formatDuration(kickVoteWindow)))
+}
+
+// findServerPlayer returns the player on the server whose in-game name
+// contains fragment, ignoring case. A name equal to fragment wins over names
+// only containing it. If there isn't exactly one such player, the player
+// who ran !kick is told so and false is returned.
+func (s *Server) findServerPlayer(data TF2RconWrapper.PlayerData, fragment string) (TF2RconWrapper.Player, bool) {
+	players, err := s.rcon.GetPlayers()
+	if err != nil {
+		s.reply(data, "!kick: Couldn't get the players on the server, try using the slot.")
+		return TF2RconWrapper.Player{}, false
+	}
+
+	fragment = strings.ToLower(fragment)
+	var matches, exact []TF2RconWrapper.Player
+	for _, player := range players {
+		name := strings.ToLower(player.Username)
+		if name == fragment {
+			exact = append(exact, player)
+		}
+		if strings.Contains(name, fragment) {
+			matches = append(matches, player)
+		}
+	}
+
+	switch {
+	case len(exact) == 1:
+		return exact[0], true
+	case len(matches) == 1:
+		return matches[0], true
+	case len(matches) == 0:
+		s.reply(data, fmt.Sprintf("!kick: Nobody on the server is called %q.", fragment))
+	default:
+		s.reply(data, fmt.Sprintf("!kick: %d players match %q. Use more of the name, or the team and slot.", len(matches), fragment))
+	}
+	return TF2RconWrapper.Player{}, false
+}
+
+// kick kicks the player from the server and keeps them from joining again,
+// and tells Helen about it.
+func (s *Server) kick(req kickRequest, kickedBy string, votes int) {
+	s.kickMu.Lock()
+	s.kicked[req.target] = true
+	s.kickMu.Unlock()
+	s.saveState()
+
+	publishEvent(Event{
+		Name:    PlayerKicked,
+		LobbyID: s.LobbyId,
+		SteamID: req.target,
+		Payload: &KickPayload{
+			SteamID:  req.target,
+			Team:     req.team,
+			Slot:     req.slot,
+			KickedBy: kickedBy,
+			Votes:    votes,
+		}})
+
+	present, err := s.kickFromServer(req.target)
+	switch {
+	case err != nil:
+		helpers.Logger.Errorf("#%d: Couldn't kick %s: %v", s.LobbyId, req.target, err)
+		s.rcon.Say(fmt.Sprintf("Couldn't kick %s from the server, but they can't join again.", req.name))
+	case !present:
+		s.rcon.Say(fmt.Sprintf("%s isn't on the server, but can't join again.", req.name))
+	default:
+		s.rcon.Say("Kicked " + req.name)
+	}
+}
+
+// kickFromServer kicks the player with the given community ID, returning
+// false if they aren't on the server
+func (s *Server) kickFromServer(commID string) (bool, error) {
+	steamID, _ := steamid.CommIdToSteamId(commID)
+
+	players, err := s.rcon.GetPlayers()
+	if err != nil {
+		return false, err
+	}
+
+	for _, player := range players {
+		if steamid.SteamIDsEqual(steamID, player.SteamID) {
+			return true, s.rcon.KickPlayer(player, kickReason)
+		}
+	}
+	return false, nil
+}
+
+// isKicked reports whether the player with the given community ID has been
+// kicked with !kick
+func (s *Server) isKicked(commID string) bool {
+	s.kickMu.Lock()
+	defer s.kickMu.Unlock()
+
+	return s.kicked[commID]
+}
+
+func init() {
+	RegisterCommand(&Command{
+		Name:     "kick",
+		Usage:    "our/their/red/blu <slot> | <name>",
+		Help:     "vote to kick a teammate or a player outside the lobby, leaders kick instantly",
+		MinArgs:  1,
+		Cooldown: 5 * time.Second,
+		Run:      kickCommand,
+	})
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKicksNeeded(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, 3, kicksNeeded(format.Sixes))
+	assert.Equal(t, 5, kicksNeeded(format.Highlander))
+	assert.Equal(t, 1, kicksNeeded(format.Ultiduo))
+	assert.Equal(t, 7, outsiderKicksNeeded(format.Sixes))
+}
+
+func kickBy(voter, voterTeam string) kickRequest {
+	return kickRequest{target: "target", team: "red", slot: "scout1", voter: voter, voterTeam: voterTeam}
+}
+
+func TestKickVotes(t *testing.T) {
+	t.Parallel()
+	s := NewServer()
+	expired := func() { t.Error("vote expired") }
+
+	votes, kick, err := s.castKickVote(kickBy("a", "red"), 3, time.Minute, expired)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, votes)
+	assert.False(t, kick)
+
+	_, _, err = s.castKickVote(kickBy("a", "red"), 3, time.Minute, expired)
+	assert.Equal(t, errKickVoted, err)
+
+	votes, kick, _ = s.castKickVote(kickBy("b", "red"), 3, time.Minute, expired)
+	assert.Equal(t, 2, votes)
+	assert.False(t, kick)
+
+	votes, kick, _ = s.castKickVote(kickBy("c", "red"), 3, time.Minute, expired)
+	assert.Equal(t, 3, votes)
+	assert.True(t, kick)
+	assert.Empty(t, s.kickVotes)
+
+	// a new vote starts from scratch
+	votes, _, _ = s.castKickVote(kickBy("a", "red"), 3, time.Minute, expired)
+	assert.Equal(t, 1, votes)
+	s.stopVotes()
+}
+
+func TestKickInstant(t *testing.T) {
+	t.Parallel()
+	s := NewServer()
+
+	req := kickBy("leader", "")
+	req.instant = true
+	_, kick, err := s.castKickVote(req, 3, time.Minute, nil)
+	assert.NoError(t, err)
+	assert.True(t, kick)
+
+	// players who aren't in the lobby too
+	req.team, req.slot = "", ""
+	_, kick, err = s.castKickVote(req, 3, time.Minute, nil)
+	assert.NoError(t, err)
+	assert.True(t, kick)
+	assert.Empty(t, s.kickVotes)
+}
+
+func TestKickOutsider(t *testing.T) {
+	t.Parallel()
+	s := NewServer()
+	outsider := func(voter, voterTeam string) kickRequest {
+		return kickRequest{target: "target", voter: voter, voterTeam: voterTeam}
+	}
+
+	// both teams vote against players who aren't in the lobby
+	votes, kick, err := s.castKickVote(outsider("a", "red"), 2, time.Minute, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, votes)
+	assert.False(t, kick)
+
+	_, _, err = s.castKickVote(outsider("b", ""), 2, time.Minute, nil)
+	assert.Equal(t, errKickVoter, err)
+
+	votes, kick, err = s.castKickVote(outsider("c", "blu"), 2, time.Minute, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, votes)
+	assert.True(t, kick)
+}
+
+func TestKickTeamOnly(t *testing.T) {
+	t.Parallel()
+	s := NewServer()
+
+	_, _, err := s.castKickVote(kickBy("a", "blu"), 3, time.Minute, nil)
+	assert.Equal(t, errKickTeam, err)
+	_, _, err = s.castKickVote(kickBy("a", ""), 3, time.Minute, nil)
+	assert.Equal(t, errKickVoter, err)
+	_, _, err = s.castKickVote(kickBy("target", "red"), 3, time.Minute, nil)
+	assert.Equal(t, errKickSelf, err)
+	assert.Empty(t, s.kickVotes)
+}
+
+func TestKickVoteExpires(t *testing.T) {
+	t.Parallel()
+	s := NewServer()
+	expired := make(chan struct{})
+
+	_, _, err := s.castKickVote(kickBy("a", "red"), 3, 10*time.Millisecond, func() { close(expired) })
+	assert.NoError(t, err)
+
+	select {
+	case <-expired:
+	case <-time.After(time.Second):
+		t.Fatal("vote didn't expire")
+	}
+
+	s.kickMu.Lock()
+	assert.Empty(t, s.kickVotes)
+	s.kickMu.Unlock()
+
+	votes, _, _ := s.castKickVote(kickBy("a", "red"), 3, time.Minute, nil)
+	assert.Equal(t, 1, votes)
+	s.stopVotes()
+}
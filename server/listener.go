@@ -1,7 +1,8 @@
 package server
 
 import (
-	"fmt"
+	"bytes"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"strings"
@@ -12,6 +13,7 @@ import (
 	"github.com/TF2Stadium/Pauling/config"
 	"github.com/TF2Stadium/Pauling/database"
 	"github.com/TF2Stadium/Pauling/helpers"
+	"github.com/TF2Stadium/Pauling/metrics"
 	"github.com/TF2Stadium/Pauling/server/logs"
 	"github.com/TF2Stadium/PlayerStatsScraper/steamid"
 	"github.com/TF2Stadium/TF2RconWrapper"
@@ -20,8 +22,18 @@ import (
 var (
 	Listener   *TF2RconWrapper.Listener
 	externalIP = getlocalip()
+
+	// when the listener last got a log line, as UnixNano
+	lastLogLine int64
+
+	// ErrNoLogs is returned by ListenerState if servers are managed, but
+	// none of them has sent logs for listenerTimeout
+	ErrNoLogs = errors.New("No log lines received.")
 )
 
+// listenerTimeout is how long servers can be quiet before ListenerState fails
+const listenerTimeout = 5 * time.Minute
+
 func getlocalip() string {
 	resp, err := http.Get("http://api.ipify.org")
 	if err != nil {
@@ -41,12 +53,38 @@ func StartListener() {
 	}
 
 	helpers.Logger.Info("Listening for server messages on %s:%s", externalIP, config.Constants.LogsPort)
+	atomic.StoreInt64(&lastLogLine, time.Now().UnixNano())
 
-	connectMQ()
+	setupSinks()
+	if config.Constants.LogExchange != "" {
+		connectLogExchange()
+	}
+}
+
+// ListenerState returns an error if the listener isn't running, or if it
+// stopped receiving logs while there are servers to receive them from. Every
+// managed server is told to send its logs by the verifier, and at least sends
+// the verifier's own rcon commands.
+func ListenerState() error {
+	if Listener == nil {
+		return errors.New("Not listening.")
+	}
+
+	last := time.Unix(0, atomic.LoadInt64(&lastLogLine))
+	if len(AllServers()) != 0 && time.Since(last) > listenerTimeout {
+		return ErrNoLogs
+	}
+	return nil
 }
 
 func (s *Server) PlayerConnected(data TF2RconWrapper.PlayerData) {
 	commID, _ := steamid.SteamIdToCommId(data.SteamId)
+	s.forgetLobbyTeams()
+	if s.isKicked(commID) {
+		s.rcon.KickPlayerID(data.UserId, kickReason)
+		return
+	}
+
 	allowed, reason := database.IsAllowed(s.LobbyId, commID)
 	if allowed {
 		publishEvent(Event{
@@ -65,10 +103,14 @@ func (s *Server) PlayerConnected(data TF2RconWrapper.PlayerData) {
 }
 
 func (s *Server) RconCommand(_, command string) {
+	s.parseDemoCommand(command)
+
 	if strings.Contains(command, `Reservation ended, every player can download the STV demo at`) {
+		s.setDemoURL(command)
 		publishEvent(Event{
 			Name:    ReservationOver,
-			LobbyID: s.LobbyId})
+			LobbyID: s.LobbyId,
+			Payload: &ReservationOverPayload{Demos: s.Demos()}})
 
 		s.StopListening()
 	}
@@ -87,66 +129,69 @@ func (s *Server) PlayerDisconnected(data TF2RconWrapper.PlayerData) {
 
 func (s *Server) TournamentStarted() {
 	ExecFile("soap_off.cfg", s.rcon)
+	if atomic.CompareAndSwapInt64(s.matchStarted, 0, time.Now().UnixNano()) {
+		s.saveState()
+	}
 }
 
 func (s *Server) PlayerGlobalMessage(data TF2RconWrapper.PlayerData, text string) {
-	switch {
-	case strings.HasPrefix(text, "!rep"):
-		s.report(data)
-	case strings.HasPrefix(text, "!sub"):
-		if rFirstSubArg.FindStringSubmatch(text) != nil {
-			// If they tried to use !sub with an argument, they
-			// probably meant to !rep
-			s.rcon.Say("!sub is for replacing yourself, !rep reports others.")
-		} else {
-			commID, _ := steamid.SteamIdToCommId(data.SteamId)
-
-			publishEvent(Event{
-				Name:    PlayerSubstituted,
-				LobbyID: s.LobbyId,
-				SteamID: commID,
-				Self:    true})
-
-			say := fmt.Sprintf("Reporting player %s (%s)",
-				data.Username, data.SteamId)
-			s.rcon.Say(say)
-		}
-	case strings.HasPrefix(text, "!soapoff"):
-		ExecFile("soap_off.cfg", s.rcon)
-	case strings.HasPrefix(text, "!help"):
-		s.rcon.Say(`Use !rep for reporting, !sub for substituting yourself.`)
-	case strings.HasPrefix(text, "!kick"):
+	s.relayChat(data, text, false)
+	s.runCommand(data, text, false)
+}
 
-	}
+// PlayerTeamMessage relays team chat, and runs chat commands said in it. Team
+// chat is where players are expected to !rep and !sub, and !kick teammates.
+func (s *Server) PlayerTeamMessage(data TF2RconWrapper.PlayerData, text string) {
+	s.relayChat(data, text, true)
+	s.runCommand(data, text, true)
 }
 
 func (s *Server) GameOver() {
 	if s.hasEnded() {
 		return
 	}
-	atomic.StoreInt32(s.ended, 1)
+	if !startGameOver() {
+		helpers.Logger.Warningf("#%d: Ignoring the end of the match, shutting down", s.LobbyId)
+		return
+	}
+	defer gameOvers.Done()
+	if !atomic.CompareAndSwapInt32(s.ended, 0, 1) {
+		return
+	}
 
-	logsBuff := s.source.Logs()
-	logsBuff.WriteString("L " + time.Now().Format(TF2RconWrapper.TimeFormat) + ": Log file closed.\n")
+	s.readLogs()
+	s.logsMu.Lock()
+	s.logs.WriteString("L " + time.Now().Format(TF2RconWrapper.TimeFormat) + ": Log file closed.\n")
+	logsBuff := bytes.NewBuffer(append([]byte(nil), s.logs.Bytes()...))
+	s.logsMu.Unlock()
 
 	var logID int
 	if config.Constants.LogsTFAPIKey != "" {
 		var err error
 
-		logID, err = logs.Upload(fmt.Sprintf("TF2Stadium Lobby #%d", s.LobbyId), s.Map, logsBuff)
-		if err != nil {
-			helpers.Logger.Warningf("%d: %s", s.LobbyId, err.Error())
-			ioutil.WriteFile(fmt.Sprintf("%d.log", s.LobbyId), logsBuff.Bytes(), 0666)
+		logID, err = logs.Upload(uploadTitle(s.LobbyId), s.Map, logsBuff)
+		s.archiveLogs(logsBuff.Bytes(), logID)
+		if logs.Permanent(err) {
+			helpers.Logger.Errorf("%d: Couldn't upload logs: %s", s.LobbyId, err.Error())
+			metrics.Uploads.Inc("failure")
+		} else if err != nil {
+			helpers.Logger.Warningf("%d: %s, retrying later", s.LobbyId, err.Error())
+			metrics.Uploads.Inc("failure")
+			queueUpload(s.LobbyId, s.Map)
+		} else {
+			metrics.Uploads.Inc("success")
 		}
 	} else {
-		helpers.Logger.Debug("No logs.tf API key, writing logs to file")
-		ioutil.WriteFile(fmt.Sprintf("%d.log", s.LobbyId), logsBuff.Bytes(), 0666)
+		helpers.Logger.Debug("No logs.tf API key, only archiving logs")
+		s.archiveLogs(logsBuff.Bytes(), logID)
 	}
 
 	publishEvent(Event{
 		Name:    MatchEnded,
 		LobbyID: s.LobbyId,
-		LogsID:  logID})
+		LogsID:  logID,
+		Payload: &MatchEndedPayload{LogsID: logID, Demos: s.Demos()}})
+	s.publishFinalStats()
 
 	s.StopListening()
 	return
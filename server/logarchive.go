@@ -0,0 +1,51 @@
+package server
+
+import (
+	"time"
+
+	"github.com/TF2Stadium/Pauling/config"
+	"github.com/TF2Stadium/Pauling/helpers"
+	"github.com/TF2Stadium/Pauling/server/archive"
+)
+
+// Archive stores the logs of every lobby that has ended
+var Archive *archive.Archive
+
+// OpenArchive opens the log archive, periodically prunes old logs and retries
+// failed logs.tf uploads
+func OpenArchive() {
+	var err error
+
+	Archive, err = archive.New(config.Constants.LogArchiveDir, config.Constants.LogRetention)
+	if err != nil {
+		helpers.Logger.Fatal(err)
+	}
+
+	go func() {
+		for {
+			if err := Archive.Prune(); err != nil {
+				helpers.Logger.Error(err.Error())
+			}
+			time.Sleep(time.Hour)
+		}
+	}()
+
+	if config.Constants.LogsTFAPIKey != "" {
+		go retryUploads()
+	}
+}
+
+func (s *Server) archiveLogs(logs []byte, logsID int) {
+	err := Archive.Store(archive.Meta{
+		LobbyID: s.LobbyId,
+		Map:     s.Map,
+		Server:  s.Info.Host,
+		Start:   s.Started,
+		End:     time.Now(),
+		LogsID:  logsID,
+	}, logs)
+
+	if err != nil {
+		helpers.Logger.Errorf("#%d: Couldn't archive logs: %v", s.LobbyId, err)
+	}
+}
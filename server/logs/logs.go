@@ -4,9 +4,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"mime/multipart"
 	"net/http"
-	"runtime"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/TF2Stadium/Pauling/config"
@@ -23,11 +27,90 @@ type response struct {
 	Success bool   `json:"success"`
 }
 
-var client = http.Client{
-	Timeout: 10 * time.Second,
+// Limits enforced by logs.tf
+const (
+	MaxLogSize     = 5 * 1024 * 1024
+	MaxTitleLen    = 40
+	MaxMapLen      = 24
+	MaxUploaderLen = 40
+)
+
+var (
+	ErrTooLarge   = errors.New("Log file is larger than 5 MB.")
+	ErrInvalidKey = errors.New("Invalid API key")
+)
+
+// Permanent reports whether uploading the same log again would fail with
+// err again
+func Permanent(err error) bool {
+	return err == ErrTooLarge || err == ErrInvalidKey
 }
 
+// Client uploads logs to logs.tf, or anything with the same API.
+type Client struct {
+	BaseURL  string // e.g. http://logs.tf
+	APIKey   string
+	Uploader string // name of the uploading software
+
+	HTTPClient *http.Client
+}
+
+// Log is a single log file to be uploaded
+type Log struct {
+	Title string
+	Map   string // optional
+	// optional team names
+	Red string
+	Blu string
+
+	Data []byte
+}
+
+func NewClient(baseURL, apiKey, uploader string, timeout time.Duration) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		APIKey:     apiKey,
+		Uploader:   uploader,
+		HTTPClient: &http.Client{Timeout: timeout},
+	}
+}
+
+var (
+	defaultOnce   sync.Once
+	defaultClient *Client
+)
+
+// Upload uploads logs with a client configured from config.Constants
 func Upload(title, mapName string, logs *bytes.Buffer) (int, error) {
+	defaultOnce.Do(func() {
+		defaultClient = NewClient(config.Constants.LogsTFURL, config.Constants.LogsTFAPIKey,
+			config.Constants.LogsTFUploader, config.Constants.LogsTFTimeout)
+	})
+
+	return defaultClient.Upload(Log{
+		Title: title,
+		Map:   mapName,
+		Red:   config.Constants.LogsTFRedName,
+		Blu:   config.Constants.LogsTFBluName,
+		Data:  logs.Bytes(),
+	})
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
+
+// Upload uploads log, returning the logs.tf ID. The title, map and uploader
+// are truncated to the lengths logs.tf allows.
+func (c *Client) Upload(log Log) (int, error) {
+	if len(log.Data) > MaxLogSize {
+		return 0, ErrTooLarge
+	}
+
 	//To upload logs, make a multipart/form-data POST request to http://logs.tf/upload
 	body := new(bytes.Buffer)
 	writer := multipart.NewWriter(body)
@@ -37,35 +120,54 @@ func Upload(title, mapName string, logs *bytes.Buffer) (int, error) {
 		return 0, err
 	}
 
-	part.Write(logs.Bytes())
+	part.Write(log.Data)
 	//title-Title of your log. Max length 40 chars.
-	writer.WriteField("title", title)
+	writer.WriteField("title", truncate(log.Title, MaxTitleLen))
 	//map-TF2 map. Optional. Max length 24 chars.
-	writer.WriteField("map", mapName)
+	if log.Map != "" {
+		writer.WriteField("map", truncate(log.Map, MaxMapLen))
+	}
+	if log.Red != "" {
+		writer.WriteField("red", log.Red)
+	}
+	if log.Blu != "" {
+		writer.WriteField("blue", log.Blu)
+	}
 	//uploader-Optional Name of the uploading plugin or software (including version). Max length 40 chars.
-	writer.WriteField("uploader", "TF2Stadium")
+	writer.WriteField("uploader", truncate(c.Uploader, MaxUploaderLen))
 	//key-Your unique key, see "Logs.tf API key" on upload page.
-	writer.WriteField("key", config.Constants.LogsTFAPIKey)
+	writer.WriteField("key", c.APIKey)
 	writer.Close()
 
-	req, err := http.NewRequest("POST", "http://logs.tf/upload", body)
+	req, err := http.NewRequest("POST", c.BaseURL+"/upload", body)
+	if err != nil {
+		return 0, err
+	}
 	req.Header.Add("Content-Type", writer.FormDataContentType())
-	req.Header.Add("User-Agent", runtime.Version())
+	req.Header.Add("User-Agent", c.Uploader)
 
-	re, err := client.Do(req)
+	re, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return 0, err
 	}
+	defer func() {
+		io.Copy(ioutil.Discard, re.Body)
+		re.Body.Close()
+	}()
 
 	resp := response{}
-	dec := json.NewDecoder(re.Body)
-	dec.Decode(&resp)
+	if err := json.NewDecoder(re.Body).Decode(&resp); err != nil {
+		return 0, fmt.Errorf("couldn't decode logs.tf response (%s): %v", re.Status, err)
+	}
 
 	return checkSuccess(resp)
 }
 
 func checkSuccess(resp response) (int, error) {
 	if !resp.Success {
+		if resp.Error == ErrInvalidKey.Error() {
+			return 0, ErrInvalidKey
+		}
 		return 0, errors.New(resp.Error)
 	}
 
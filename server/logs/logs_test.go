@@ -0,0 +1,99 @@
+package logs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// upload is what fakeLogsTF received, copied before the request is done
+type upload struct {
+	form url.Values
+	file string
+}
+
+// fakeLogsTF mimics the logs.tf upload API, rejecting uploads with the wrong
+// key.
+func fakeLogsTF(t *testing.T, uploads chan<- upload) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/upload", r.URL.Path)
+		assert.NoError(t, r.ParseMultipartForm(MaxLogSize))
+
+		u := upload{form: url.Values{}}
+		for key, values := range r.MultipartForm.Value {
+			u.form[key] = append([]string(nil), values...)
+		}
+		if file, _, err := r.FormFile("logfile"); assert.NoError(t, err) {
+			data, _ := ioutil.ReadAll(file)
+			u.file = string(data)
+		}
+		uploads <- u
+
+		if u.form.Get("key") != "key" {
+			json.NewEncoder(w).Encode(response{Error: "Invalid API key"})
+			return
+		}
+		json.NewEncoder(w).Encode(response{Success: true, LogID: 1234})
+	}))
+}
+
+func TestUpload(t *testing.T) {
+	t.Parallel()
+	uploads := make(chan upload, 1)
+	server := fakeLogsTF(t, uploads)
+	defer server.Close()
+
+	client := NewClient(server.URL, "key", "TF2Stadium", time.Second)
+	id, err := client.Upload(Log{Title: "TF2Stadium Lobby #1", Map: "cp_badlands", Red: "RED", Data: []byte("L log\n")})
+	assert.NoError(t, err)
+	assert.Equal(t, 1234, id)
+
+	u := <-uploads
+	assert.Equal(t, "TF2Stadium Lobby #1", u.form.Get("title"))
+	assert.Equal(t, "cp_badlands", u.form.Get("map"))
+	assert.Equal(t, "RED", u.form.Get("red"))
+	assert.Equal(t, "TF2Stadium", u.form.Get("uploader"))
+	assert.Equal(t, "L log\n", u.file)
+}
+
+func TestUploadError(t *testing.T) {
+	t.Parallel()
+	uploads := make(chan upload, 1)
+	server := fakeLogsTF(t, uploads)
+	defer server.Close()
+
+	client := NewClient(server.URL, "wrong key", "TF2Stadium", time.Second)
+	_, err := client.Upload(Log{Title: "TF2Stadium Lobby #1", Data: []byte("L log\n")})
+	assert.Equal(t, ErrInvalidKey, err)
+	assert.True(t, Permanent(err))
+	<-uploads
+}
+
+func TestUploadLimits(t *testing.T) {
+	t.Parallel()
+	uploads := make(chan upload, 1)
+	server := fakeLogsTF(t, uploads)
+	defer server.Close()
+
+	client := NewClient(server.URL, "key", "TF2Stadium", time.Second)
+	_, err := client.Upload(Log{
+		Title: strings.Repeat("t", 50),
+		Map:   strings.Repeat("m", 30),
+		Data:  []byte("L log\n"),
+	})
+	assert.NoError(t, err)
+
+	u := <-uploads
+	assert.Len(t, u.form.Get("title"), MaxTitleLen)
+	assert.Len(t, u.form.Get("map"), MaxMapLen)
+
+	_, err = client.Upload(Log{Title: "too large", Data: make([]byte, MaxLogSize+1)})
+	assert.Equal(t, ErrTooLarge, err)
+}
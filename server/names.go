@@ -0,0 +1,119 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TF2Stadium/Pauling/database"
+	"github.com/TF2Stadium/PlayerStatsScraper/steamid"
+	"github.com/TF2Stadium/TF2RconWrapper"
+)
+
+// maxNameMatches is the most names listed when a name fragment matches
+// several players
+const maxNameMatches = 4
+
+// nameMatch is a lobby player whose name matched a fragment
+type nameMatch struct {
+	player database.LobbyPlayer
+	name   string // the name which matched, in-game if both did
+	exact  bool   // name is equal to the fragment
+}
+
+// matchPlayers returns the lobby players on the server whose in-game or
+// TF2Stadium name contains fragment, ignoring case. fragment must be in
+// lower case.
+func matchPlayers(onServer []TF2RconWrapper.Player, lobby []database.LobbyPlayer, fragment string) []nameMatch {
+	byID := make(map[string]database.LobbyPlayer)
+	for _, player := range lobby {
+		byID[player.SteamID] = player
+	}
+
+	var matches []nameMatch
+	for _, player := range onServer {
+		commID, err := steamid.SteamIdToCommId(player.SteamID)
+		if err != nil {
+			continue
+		}
+		lobbyPlayer, ok := byID[commID]
+		if !ok {
+			continue
+		}
+
+		match := nameMatch{player: lobbyPlayer}
+		for _, name := range []string{player.Username, lobbyPlayer.Name} {
+			lower := strings.ToLower(name)
+			if lower == "" || !strings.Contains(lower, fragment) {
+				continue
+			}
+			if match.name == "" {
+				match.name = name
+			}
+			match.exact = match.exact || lower == fragment
+		}
+
+		if match.name != "" {
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// pickPlayer returns the only match, or the only exact match if there are
+// several matches
+func pickPlayer(matches []nameMatch) (database.LobbyPlayer, bool) {
+	if len(matches) == 1 {
+		return matches[0].player, true
+	}
+
+	var exact []nameMatch
+	for _, match := range matches {
+		if match.exact {
+			exact = append(exact, match)
+		}
+	}
+	if len(exact) == 1 {
+		return exact[0].player, true
+	}
+	return database.LobbyPlayer{}, false
+}
+
+// findPlayer returns the lobby player on the server whose in-game or
+// TF2Stadium name contains fragment, ignoring case. If there isn't exactly
+// one such player, the reporter is told so and false is returned. A name
+// equal to fragment wins over names only containing it.
+func (s *Server) findPlayer(data TF2RconWrapper.PlayerData, fragment string) (database.LobbyPlayer, bool) {
+	players, err := s.rcon.GetPlayers()
+	if err != nil {
+		s.reply(data, "!rep: Couldn't get the players on the server, try using the slot.")
+		return database.LobbyPlayer{}, false
+	}
+	lobby, err := database.GetLobbyPlayers(s.LobbyId, s.Type)
+	if err != nil {
+		s.reply(data, "!rep: Couldn't get the lobby's players, try using the slot.")
+		return database.LobbyPlayer{}, false
+	}
+
+	fragment = strings.ToLower(fragment)
+	matches := matchPlayers(players, lobby, fragment)
+	if player, ok := pickPlayer(matches); ok {
+		return player, true
+	}
+
+	if len(matches) == 0 {
+		s.reply(data, fmt.Sprintf("!rep: Nobody in the lobby is called %q.", fragment))
+		return database.LobbyPlayer{}, false
+	}
+
+	var list []string
+	for i, match := range matches {
+		if i == maxNameMatches {
+			list = append(list, "...")
+			break
+		}
+		list = append(list, match.name)
+	}
+	s.reply(data, fmt.Sprintf("!rep: %d players match %q: %s. Use more of the name, or the team and slot.",
+		len(matches), fragment, strings.Join(list, ", ")))
+	return database.LobbyPlayer{}, false
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/TF2Stadium/Pauling/database"
+	"github.com/TF2Stadium/PlayerStatsScraper/steamid"
+	"github.com/TF2Stadium/TF2RconWrapper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPlayers(t *testing.T) {
+	t.Parallel()
+	onServer := []TF2RconWrapper.Player{
+		{UserID: 1, Username: "Sniper", SteamID: "[U:1:1]"},
+		{UserID: 2, Username: "sniperino", SteamID: "[U:1:2]"},
+		{UserID: 3, Username: "pyro", SteamID: "[U:1:3]"},
+		{UserID: 4, Username: "Spectator", SteamID: "[U:1:4]"}, // not in the lobby
+	}
+	names := []string{"sniper main", "sniperino", "Grill"}
+
+	var lobby []database.LobbyPlayer
+	for i, player := range onServer[:3] {
+		commID, err := steamid.SteamIdToCommId(player.SteamID)
+		assert.NoError(t, err)
+		lobby = append(lobby, database.LobbyPlayer{SteamID: commID, Name: names[i], Team: "red", Class: "scout1"})
+	}
+
+	cases := []struct {
+		fragment string
+		names    []string // matched names
+		picked   int      // index in lobby, -1 for none
+	}{
+		{"sniper", []string{"Sniper", "sniperino"}, 0},
+		{"snip", []string{"Sniper", "sniperino"}, -1},
+		{"main", []string{"sniper main"}, 0},
+		{"grill", []string{"Grill"}, 2},
+		{"spec", nil, -1},
+		{"heavy", nil, -1},
+	}
+
+	for _, c := range cases {
+		matches := matchPlayers(onServer, lobby, c.fragment)
+		var matched []string
+		for _, match := range matches {
+			matched = append(matched, match.name)
+		}
+		assert.Equal(t, c.names, matched, c.fragment)
+
+		player, ok := pickPlayer(matches)
+		if c.picked == -1 {
+			assert.False(t, ok, c.fragment)
+		} else if assert.True(t, ok, c.fragment) {
+			assert.Equal(t, lobby[c.picked], player, c.fragment)
+		}
+	}
+}
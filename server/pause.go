@@ -0,0 +1,296 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/TF2Stadium/Pauling/config"
+	"github.com/TF2Stadium/Pauling/helpers"
+)
+
+// pauseAnnounceInterval is how often the remaining pause time is said
+const pauseAnnounceInterval = 30 * time.Second
+
+// PauseRules are a league's rules for pausing matches
+type PauseRules struct {
+	Pauses      int           // per team
+	MaxDuration time.Duration // the match is unpaused after this long
+	ReadyMode   bool          // mp_tournament_readymode, players ready up instead of teams
+}
+
+// configPauseRules returns the pause rules set in the config, used for
+// leagues without their own
+func configPauseRules() PauseRules {
+	return PauseRules{
+		Pauses:      config.Constants.Pauses,
+		MaxDuration: config.Constants.PauseDuration,
+		ReadyMode:   config.Constants.ReadyMode,
+	}
+}
+
+// loadPauseRules reads the league's pause rules from
+// configs/<league>/pauses.json
+func loadPauseRules(league string) PauseRules {
+	return readPauseRules("./configs", league)
+}
+
+// readPauseRules reads the league's pause rules from dir, taking fields
+// missing from the file, or all of them if there isn't one, from the config.
+//
+//	{"Pauses": 1, "MaxDuration": "3m", "ReadyMode": false}
+func readPauseRules(dir, league string) PauseRules {
+	rules := configPauseRules()
+
+	path, _ := filepath.Abs(filepath.Join(dir, league, "pauses.json"))
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			helpers.Logger.Errorf("Couldn't read %s: %v", path, err)
+		}
+		return rules
+	}
+
+	file := struct {
+		Pauses      int
+		MaxDuration config.Duration
+		ReadyMode   bool
+	}{rules.Pauses, config.Duration(rules.MaxDuration), rules.ReadyMode}
+	if err := json.Unmarshal(data, &file); err != nil {
+		helpers.Logger.Errorf("Couldn't parse %s: %v", path, err)
+		return rules
+	}
+
+	return PauseRules{
+		Pauses:      file.Pauses,
+		MaxDuration: time.Duration(file.MaxDuration),
+		ReadyMode:   file.ReadyMode,
+	}
+}
+
+// pauseState is the server's pause state, guarded by Server.pauseMu
+type pauseState struct {
+	rules PauseRules
+
+	paused   bool
+	team     string // team which paused
+	pausedAt time.Time
+	unpause  chan struct{} // closed to stop the running pause
+
+	used map[string]int // pauses used by each team
+}
+
+var (
+	errPauseEnded    = errors.New("!pause: The match is over.")
+	errPauseOutsider = errors.New("!pause: Only players in the lobby can pause.")
+	errPausePaused   = errors.New("!pause: The match is already paused.")
+)
+
+// check returns the pauses team has left, or why it can't pause. Admins
+// can always pause, even if they aren't in the lobby (team is "").
+func (state *pauseState) check(team string, admin, ended bool) (int, error) {
+	switch {
+	case ended:
+		return 0, errPauseEnded
+	case team == "" && !admin:
+		return 0, errPauseOutsider
+	case state.paused:
+		return 0, errPausePaused
+	}
+
+	left := state.rules.Pauses - state.used[team]
+	if left <= 0 && !admin {
+		return 0, fmt.Errorf("!pause: %s has no pauses left.", strings.ToUpper(team))
+	}
+	return left, nil
+}
+
+// start marks the match as paused by team, using one of its pauses
+func (state *pauseState) start(team string, now time.Time) {
+	if team != "" {
+		state.used[team]++
+	}
+	state.paused = true
+	state.team = team
+	state.pausedAt = now
+	state.unpause = make(chan struct{})
+}
+
+// PausePayload is the payload for matchPaused events
+type PausePayload struct {
+	SteamID     string // player who paused
+	Team        string
+	PausesLeft  int // for the team
+	MaxDuration config.Duration
+}
+
+// UnpausePayload is the payload for matchUnpaused events
+type UnpausePayload struct {
+	SteamID  string // player who unpaused, empty if the pause ran out
+	Team     string // team which paused
+	Duration config.Duration
+}
+
+func formatDuration(d time.Duration) string {
+	d = (d + time.Second/2) / time.Second * time.Second
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+func pauseCommand(s *Server, ctx *CommandContext) {
+	team := ctx.Team
+
+	s.pauseMu.Lock()
+	state := &s.pause
+	left, err := state.check(team, isAdmin(ctx.CommID), s.hasEnded())
+	if err != nil {
+		s.pauseMu.Unlock()
+		s.reply(ctx.Player, err.Error())
+		return
+	}
+
+	if _, err := s.rcon.Query("sv_pausable 1; pause; sv_pausable 0"); err != nil {
+		s.pauseMu.Unlock()
+		helpers.Logger.Errorf("#%d: Couldn't pause: %v", s.LobbyId, err)
+		s.reply(ctx.Player, "!pause: Couldn't pause the match.")
+		return
+	}
+
+	state.start(team, time.Now())
+	if team != "" {
+		left--
+	}
+	maxDuration := state.rules.MaxDuration
+	go s.pauseTimer(state.unpause, maxDuration)
+	s.pauseMu.Unlock()
+	s.saveState()
+
+	publishEvent(Event{
+		Name:    MatchPaused,
+		LobbyID: s.LobbyId,
+		SteamID: ctx.CommID,
+		Payload: &PausePayload{
+			SteamID:     ctx.CommID,
+			Team:        team,
+			PausesLeft:  left,
+			MaxDuration: config.Duration(maxDuration),
+		}})
+
+	say := fmt.Sprintf("%s paused the match for up to %s", ctx.Player.Username, formatDuration(maxDuration))
+	if team != "" {
+		say += fmt.Sprintf(" (%d pauses left for %s)", left, strings.ToUpper(team))
+	}
+	s.rcon.Say(say + ".")
+}
+
+func unpauseCommand(s *Server, ctx *CommandContext) {
+	team := ctx.Team
+
+	s.pauseMu.Lock()
+	if !s.pause.paused {
+		s.pauseMu.Unlock()
+		s.reply(ctx.Player, "!unpause: The match isn't paused.")
+		return
+	}
+	if team != s.pause.team && !isAdmin(ctx.CommID) {
+		s.pauseMu.Unlock()
+		s.reply(ctx.Player, fmt.Sprintf("!unpause: Only %s can unpause before the pause runs out.", strings.ToUpper(s.pause.team)))
+		return
+	}
+	s.pauseMu.Unlock()
+
+	s.unpauseMatch(ctx.CommID)
+}
+
+// pauseTimer announces the remaining pause time, and unpauses the match
+// when it runs out
+func (s *Server) pauseTimer(unpause chan struct{}, maxDuration time.Duration) {
+	runPauseTimer(unpause, s.done, maxDuration, pauseAnnounceInterval,
+		func(left time.Duration) {
+			s.rcon.Say(fmt.Sprintf("The match will be unpaused in %s.", formatDuration(left)))
+		},
+		func() {
+			s.rcon.Say("The pause has run out, unpausing.")
+			s.unpauseMatch("")
+		})
+}
+
+// runPauseTimer calls announce with the time left every interval, and
+// expire once maxDuration is over, unless unpause or done are closed first.
+func runPauseTimer(unpause, done <-chan struct{}, maxDuration, interval time.Duration, announce func(time.Duration), expire func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	end := time.After(maxDuration)
+	deadline := time.Now().Add(maxDuration)
+
+	for {
+		select {
+		case <-unpause:
+			return
+		case <-done:
+			return
+		case <-ticker.C:
+			announce(deadline.Sub(time.Now()))
+		case <-end:
+			expire()
+			return
+		}
+	}
+}
+
+// unpauseMatch unpauses the match, if it's paused. steamID is the player
+// unpausing, or empty if the pause ran out.
+func (s *Server) unpauseMatch(steamID string) {
+	s.pauseMu.Lock()
+	state := &s.pause
+	if !state.paused {
+		s.pauseMu.Unlock()
+		return
+	}
+
+	if _, err := s.rcon.Query("sv_pausable 1; unpause; sv_pausable 0"); err != nil {
+		s.pauseMu.Unlock()
+		helpers.Logger.Errorf("#%d: Couldn't unpause: %v", s.LobbyId, err)
+		s.rcon.Say("Couldn't unpause the match.")
+		return
+	}
+
+	state.paused = false
+	close(state.unpause)
+	duration := time.Since(state.pausedAt)
+	team := state.team
+	s.pauseMu.Unlock()
+	s.saveState()
+
+	publishEvent(Event{
+		Name:    MatchUnpaused,
+		LobbyID: s.LobbyId,
+		SteamID: steamID,
+		Payload: &UnpausePayload{
+			SteamID:  steamID,
+			Team:     team,
+			Duration: config.Duration(duration),
+		}})
+
+	s.rcon.Say(fmt.Sprintf("Unpaused after %s.", formatDuration(duration)))
+}
+
+func init() {
+	RegisterCommand(&Command{
+		Name:     "pause",
+		Help:     "pause the match, using one of your team's pauses",
+		Cooldown: 5 * time.Second,
+		Run:      pauseCommand,
+	})
+
+	RegisterCommand(&Command{
+		Name:     "unpause",
+		Help:     "unpause the match",
+		Cooldown: 5 * time.Second,
+		Run:      unpauseCommand,
+	})
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDuration(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "5:00", formatDuration(5*time.Minute))
+	assert.Equal(t, "0:42", formatDuration(41600*time.Millisecond))
+}
+
+func TestPauseLimits(t *testing.T) {
+	t.Parallel()
+	state := pauseState{rules: PauseRules{Pauses: 1}, used: make(map[string]int)}
+
+	left, err := state.check("red", false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, left)
+	state.start("red", time.Now())
+
+	_, err = state.check("blu", false, false)
+	assert.Equal(t, errPausePaused, err)
+	state.paused = false
+
+	// each team has its own pauses
+	_, err = state.check("red", false, false)
+	assert.EqualError(t, err, "!pause: RED has no pauses left.")
+	left, err = state.check("blu", false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, left)
+
+	// admins can always pause
+	_, err = state.check("red", true, false)
+	assert.NoError(t, err)
+	state.start("", time.Now())
+	assert.Equal(t, 1, state.used["red"])
+	assert.Equal(t, 0, state.used["blu"])
+}
+
+func TestLeaguePauseRules(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "pauling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"etf2l": `{"Pauses": 1, "MaxDuration": "3m"}`,
+		"ugc":   `{"Pauses": 3, "ReadyMode": false}`,
+	}
+	for league, data := range files {
+		assert.NoError(t, os.Mkdir(filepath.Join(dir, league), 0755))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, league, "pauses.json"), []byte(data), 0644))
+	}
+
+	defaults := configPauseRules()
+	assert.Equal(t, PauseRules{Pauses: 1, MaxDuration: 3 * time.Minute, ReadyMode: defaults.ReadyMode}, readPauseRules(dir, "etf2l"))
+	assert.Equal(t, PauseRules{Pauses: 3, MaxDuration: defaults.MaxDuration, ReadyMode: false}, readPauseRules(dir, "ugc"))
+	assert.Equal(t, defaults, readPauseRules(dir, "esea"))
+}
+
+func TestPauseRejected(t *testing.T) {
+	t.Parallel()
+	state := pauseState{rules: PauseRules{Pauses: 2}, used: make(map[string]int)}
+
+	_, err := state.check("", false, false)
+	assert.Equal(t, errPauseOutsider, err)
+	_, err = state.check("red", false, true)
+	assert.Equal(t, errPauseEnded, err)
+	_, err = state.check("", true, true)
+	assert.Equal(t, errPauseEnded, err)
+}
+
+func TestPauseTimer(t *testing.T) {
+	t.Parallel()
+	unpause, done := make(chan struct{}), make(chan struct{})
+	expired := make(chan struct{})
+	announced := 0
+
+	go runPauseTimer(unpause, done, 50*time.Millisecond, 20*time.Millisecond,
+		func(left time.Duration) {
+			assert.True(t, left < 50*time.Millisecond)
+			announced++
+		},
+		func() { close(expired) })
+
+	select {
+	case <-expired:
+		assert.NotZero(t, announced)
+	case <-time.After(time.Second):
+		t.Fatal("pause didn't run out")
+	}
+
+	// unpausing stops the timer
+	stopped := make(chan struct{})
+	go func() {
+		runPauseTimer(unpause, done, time.Hour, time.Hour, nil, func() { t.Error("pause ran out") })
+		close(stopped)
+	}()
+	close(unpause)
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("timer didn't stop")
+	}
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/TF2Stadium/Pauling/metrics"
+	"github.com/TF2Stadium/TF2RconWrapper"
+)
+
+var errNotConnected = errors.New("Not connected to the server's RCON.")
+
+// rconConn is the RCON connection to a server. Every method Pauling uses is
+// wrapped, so that how long each query takes, and which ones failed, is
+// recorded in the metrics. Until connect succeeds, queries fail with
+// errNotConnected.
+type rconConn struct {
+	mu sync.RWMutex
+	c  *TF2RconWrapper.TF2RconConnection
+}
+
+// connect opens a new connection, replacing the current one
+func (c *rconConn) connect(host, password string) error {
+	conn, err := TF2RconWrapper.NewTF2RconConnection(host, password)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.c = conn
+	c.mu.Unlock()
+	return nil
+}
+
+// conn returns the wrapped connection, or nil if c isn't connected
+func (c *rconConn) conn() *TF2RconWrapper.TF2RconConnection {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.c
+}
+
+// observe runs query on the connection, and records it in the metrics
+func (c *rconConn) observe(query func(conn *TF2RconWrapper.TF2RconConnection) error) error {
+	conn := c.conn()
+	if conn == nil {
+		return errNotConnected
+	}
+
+	start := time.Now()
+	err := query(conn)
+	metrics.ObserveRcon(start, err)
+	return err
+}
+
+func (c *rconConn) Query(command string) (string, error) {
+	var resp string
+	err := c.observe(func(conn *TF2RconWrapper.TF2RconConnection) (err error) {
+		resp, err = conn.Query(command)
+		return
+	})
+	return resp, err
+}
+
+func (c *rconConn) QueryNoResp(command string) error {
+	return c.observe(func(conn *TF2RconWrapper.TF2RconConnection) error {
+		return conn.QueryNoResp(command)
+	})
+}
+
+func (c *rconConn) Say(text string) error {
+	return c.observe(func(conn *TF2RconWrapper.TF2RconConnection) error {
+		return conn.Say(text)
+	})
+}
+
+func (c *rconConn) GetPlayers() ([]TF2RconWrapper.Player, error) {
+	var players []TF2RconWrapper.Player
+	err := c.observe(func(conn *TF2RconWrapper.TF2RconConnection) (err error) {
+		players, err = conn.GetPlayers()
+		return
+	})
+	return players, err
+}
+
+func (c *rconConn) KickPlayer(player TF2RconWrapper.Player, reason string) error {
+	return c.observe(func(conn *TF2RconWrapper.TF2RconConnection) error {
+		return conn.KickPlayer(player, reason)
+	})
+}
+
+func (c *rconConn) KickPlayerID(userID string, reason string) error {
+	return c.observe(func(conn *TF2RconWrapper.TF2RconConnection) error {
+		return conn.KickPlayerID(userID, reason)
+	})
+}
+
+func (c *rconConn) GetServerPassword() (string, error) {
+	var password string
+	err := c.observe(func(conn *TF2RconWrapper.TF2RconConnection) (err error) {
+		password, err = conn.GetServerPassword()
+		return
+	})
+	return password, err
+}
+
+func (c *rconConn) ChangeServerPassword(password string) error {
+	return c.observe(func(conn *TF2RconWrapper.TF2RconConnection) error {
+		return conn.ChangeServerPassword(password)
+	})
+}
+
+func (c *rconConn) GetConVar(cvar string) (string, error) {
+	var value string
+	err := c.observe(func(conn *TF2RconWrapper.TF2RconConnection) (err error) {
+		value, err = conn.GetConVar(cvar)
+		return
+	})
+	return value, err
+}
+
+func (c *rconConn) ChangeMap(mapName string) error {
+	return c.observe(func(conn *TF2RconWrapper.TF2RconConnection) error {
+		return conn.ChangeMap(mapName)
+	})
+}
+
+func (c *rconConn) AddTag(tag string) error {
+	return c.observe(func(conn *TF2RconWrapper.TF2RconConnection) error {
+		return conn.AddTag(tag)
+	})
+}
+
+func (c *rconConn) RemoveTag(tag string) error {
+	return c.observe(func(conn *TF2RconWrapper.TF2RconConnection) error {
+		return conn.RemoveTag(tag)
+	})
+}
+
+// Reconnect reconnects to the server, trying for at most timeout. It isn't
+// a query, so it isn't recorded.
+func (c *rconConn) Reconnect(timeout time.Duration) error {
+	conn := c.conn()
+	if conn == nil {
+		return errNotConnected
+	}
+	return conn.Reconnect(timeout)
+}
+
+func (c *rconConn) Close() {
+	if conn := c.conn(); conn != nil {
+		conn.Close()
+	}
+}
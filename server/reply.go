@@ -0,0 +1,112 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/TF2Stadium/Pauling/helpers"
+	"github.com/TF2Stadium/PlayerStatsScraper/steamid"
+	"github.com/TF2Stadium/TF2RconWrapper"
+)
+
+// values of Server.sourcemod
+const (
+	pluginUnknown int32 = iota
+	pluginPresent
+	pluginMissing
+)
+
+// hasSourceMod reports whether the server has SourceMod, which is needed for
+// sending messages to single players with sm_psay. The result is cached.
+func (s *Server) hasSourceMod() bool {
+	switch atomic.LoadInt32(s.sourcemod) {
+	case pluginPresent:
+		return true
+	case pluginMissing:
+		return false
+	}
+
+	resp, err := s.rcon.Query("sm version")
+	if err != nil {
+		// don't cache, the connection might be back for the next reply
+		return false
+	}
+
+	if strings.Contains(resp, "SourceMod Version") {
+		atomic.StoreInt32(s.sourcemod, pluginPresent)
+		return true
+	}
+
+	helpers.Logger.Debugf("#%d: No SourceMod, replying in global chat", s.LobbyId)
+	atomic.StoreInt32(s.sourcemod, pluginMissing)
+	return false
+}
+
+// psay sends text to the player with the given user ID only
+func (s *Server) psay(userID int, text string) error {
+	text = strings.Replace(text, `"`, `'`, -1)
+	_, err := s.rcon.Query(fmt.Sprintf(`sm_psay #%d "%s"`, userID, text))
+	return err
+}
+
+// reply sends text to player only, or to everyone if that isn't possible.
+func (s *Server) reply(player TF2RconWrapper.PlayerData, text string) {
+	userID, err := strconv.Atoi(player.UserId)
+	if err == nil && s.hasSourceMod() && s.psay(userID, text) == nil {
+		return
+	}
+	s.rcon.Say(text)
+}
+
+// replyLines replies with each line in a separate message
+func (s *Server) replyLines(player TF2RconWrapper.PlayerData, lines []string) {
+	for _, line := range lines {
+		s.reply(player, line)
+	}
+}
+
+// replyTeam sends text to every player on the lobby team ("red" or "blu"),
+// or to everyone if that isn't possible.
+func (s *Server) replyTeam(team, text string) {
+	if !s.hasSourceMod() {
+		s.rcon.Say(text)
+		return
+	}
+
+	players, err := s.rcon.GetPlayers()
+	if err != nil {
+		s.rcon.Say(text)
+		return
+	}
+	teams, err := s.lobbyTeams()
+	if err != nil {
+		helpers.Logger.Errorf("#%d: Couldn't get the lobby's players: %v", s.LobbyId, err)
+		s.rcon.Say(text)
+		return
+	}
+
+	for _, player := range players {
+		commID, _ := steamid.SteamIdToCommId(player.SteamID)
+		if commID == "" || teams[commID] != team {
+			continue
+		}
+
+		if err := s.psay(player.UserID, text); err != nil {
+			helpers.Logger.Errorf("#%d: Couldn't send a team message: %v", s.LobbyId, err)
+			s.rcon.Say(text)
+			return
+		}
+	}
+}
+
+// replyVoters sends text to the players of the lobby team voting on a
+// player, or to everyone if team is empty because both teams vote.
+func (s *Server) replyVoters(team, text string) {
+	if team == "" {
+		s.rcon.Say(text)
+		return
+	}
+	s.replyTeam(team, text)
+}
@@ -2,7 +2,10 @@ package server
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/TF2Stadium/Pauling/config"
+	"github.com/TF2Stadium/Pauling/database"
 	"github.com/TF2Stadium/Pauling/helpers"
 	"github.com/jinzhu/gorm"
 	_ "github.com/mattn/go-sqlite3"
@@ -38,21 +41,70 @@ func CreateDB() {
 
 	// id | source_player_id | target_player_id | lobby_id
 
-	err = db.AutoMigrate(&report{}).Error
+	err = db.AutoMigrate(&report{}, &serverState{}, &outboxEvent{}, &sinkCursor{}, &lobbyStats{}, &pendingUpload{}, &lobbyDemos{}).Error
 	if err != nil {
 		helpers.Logger.Fatal(err)
 	}
 
+	switch config.Constants.ReportStore {
+	case "", "sqlite":
+		err = db.Table("vote_records").AutoMigrate(&database.VoteRecord{}).Error
+		if err != nil {
+			helpers.Logger.Fatal(err)
+		}
+		Reports = sqliteReports{}
+	case "postgres":
+		if err := database.Migrate(); err != nil {
+			helpers.Logger.Fatal(err)
+		}
+		Reports = postgresReports{}
+	default:
+		helpers.Logger.Fatalf("Unknown report store %s", config.Constants.ReportStore)
+	}
+
 }
 
-func hasReported(source, target string, lobbyID uint) bool {
+// PingDB checks if the sqlite database is usable
+func PingDB() error {
+	return db.DB().Ping()
+}
+
+// ReportStore keeps the !rep votes
+type ReportStore interface {
+	// Add records source's vote to replace target. It returns a *repError
+	// if source has already voted for it.
+	Add(source, target string, lobbyID uint) error
+	HasReported(source, target string, lobbyID uint) bool
+	Count(target string, lobbyID uint) int
+	// Reset deletes all votes to replace target
+	Reset(target string, lobbyID uint) error
+
+	// Votes are also kept in an audit log, which isn't reset
+
+	// Record adds a vote to the audit log, with a pending outcome
+	Record(source, target string, lobbyID uint) error
+	// Resolve sets the outcome of the pending votes to replace target
+	Resolve(target string, lobbyID uint, outcome string) error
+	// History returns the votes in the lobby, and/or with the player as the
+	// voter or target, latest first. A lobbyID of 0 or an empty steamID
+	// matches everything.
+	History(lobbyID uint, steamID string) ([]database.VoteRecord, error)
+}
+
+// Reports is where votes are kept, set by CreateDB
+var Reports ReportStore = sqliteReports{}
+
+// sqliteReports keeps votes in the local sqlite database
+type sqliteReports struct{}
+
+func (sqliteReports) HasReported(source, target string, lobbyID uint) bool {
 	var count int
 	db.Table("reports").Where(&report{LobbyID: lobbyID, Source: source, Target: target}).Count(&count)
 	return count != 0
 }
 
-func newReport(source, target string, lobbyID uint) error {
-	if hasReported(source, target, lobbyID) {
+func (r sqliteReports) Add(source, target string, lobbyID uint) error {
+	if r.HasReported(source, target, lobbyID) {
 		return &repError{source, target}
 	}
 
@@ -60,15 +112,128 @@ func newReport(source, target string, lobbyID uint) error {
 	return db.Table("reports").Create(rep).Error
 }
 
-//ResetReportCount resets the !rep count for the given player in lobby lobbyID
-func ResetReportCount(target string, lobbyID uint) error {
-	err := db.Table("reports").Where(&report{Target: target, LobbyID: lobbyID}).Delete(&report{}).Error
-	return err
+func (sqliteReports) Reset(target string, lobbyID uint) error {
+	return db.Table("reports").Where(&report{Target: target, LobbyID: lobbyID}).Delete(&report{}).Error
 }
 
-func countReports(target string, lobbyID uint) int {
+func (sqliteReports) Count(target string, lobbyID uint) int {
 	var count int
 
 	db.Table("reports").Where(&report{LobbyID: lobbyID, Target: target}).Count(&count)
 	return count
 }
+
+func (sqliteReports) Record(source, target string, lobbyID uint) error {
+	return db.Table("vote_records").Create(&database.VoteRecord{
+		LobbyID:   lobbyID,
+		Source:    source,
+		Target:    target,
+		Outcome:   database.VotePending,
+		CreatedAt: time.Now(),
+	}).Error
+}
+
+func (sqliteReports) Resolve(target string, lobbyID uint, outcome string) error {
+	now := time.Now()
+	return db.Table("vote_records").
+		Where("lobby_id = ? AND target = ? AND outcome = ?", lobbyID, target, database.VotePending).
+		Updates(map[string]interface{}{"outcome": outcome, "resolved_at": &now}).Error
+}
+
+func (sqliteReports) History(lobbyID uint, steamID string) ([]database.VoteRecord, error) {
+	query := db.Table("vote_records")
+	if lobbyID != 0 {
+		query = query.Where("lobby_id = ?", lobbyID)
+	}
+	if steamID != "" {
+		query = query.Where("source = ? OR target = ?", steamID, steamID)
+	}
+
+	var records []database.VoteRecord
+	err := query.Order("id desc").Find(&records).Error
+	return records, err
+}
+
+// postgresReports keeps votes in Postgres, so they're shared by every
+// Pauling instance
+type postgresReports struct{}
+
+func (postgresReports) HasReported(source, target string, lobbyID uint) bool {
+	return database.HasReported(source, target, lobbyID)
+}
+
+func (postgresReports) Add(source, target string, lobbyID uint) error {
+	added, err := database.AddReport(source, target, lobbyID)
+	if err != nil {
+		return err
+	}
+	if !added {
+		return &repError{source, target}
+	}
+	return nil
+}
+
+func (postgresReports) Reset(target string, lobbyID uint) error {
+	return database.ResetReports(target, lobbyID)
+}
+
+func (postgresReports) Count(target string, lobbyID uint) int {
+	return database.CountReports(target, lobbyID)
+}
+
+func (postgresReports) Record(source, target string, lobbyID uint) error {
+	return database.AddVoteRecord(source, target, lobbyID)
+}
+
+func (postgresReports) Resolve(target string, lobbyID uint, outcome string) error {
+	return database.ResolveVoteRecords(target, lobbyID, outcome)
+}
+
+func (postgresReports) History(lobbyID uint, steamID string) ([]database.VoteRecord, error) {
+	return database.GetVoteRecords(lobbyID, steamID)
+}
+
+func hasReported(source, target string, lobbyID uint) bool {
+	return Reports.HasReported(source, target, lobbyID)
+}
+
+func newReport(source, target string, lobbyID uint) error {
+	if err := Reports.Add(source, target, lobbyID); err != nil {
+		return err
+	}
+
+	if err := Reports.Record(source, target, lobbyID); err != nil {
+		helpers.Logger.Errorf("#%d: Couldn't record vote: %v", lobbyID, err)
+	}
+	return nil
+}
+
+// resolveReports sets the outcome of the votes to replace target
+func resolveReports(target string, lobbyID uint, outcome string) {
+	if err := Reports.Resolve(target, lobbyID, outcome); err != nil {
+		helpers.Logger.Errorf("#%d: Couldn't record vote outcome: %v", lobbyID, err)
+	}
+}
+
+//ResetReportCount resets the !rep count for the given player in lobby lobbyID
+func ResetReportCount(target string, lobbyID uint) error {
+	return Reports.Reset(target, lobbyID)
+}
+
+// CancelReports resets the !rep count for the player, and marks the pending
+// votes as cancelled
+func CancelReports(target string, lobbyID uint) error {
+	resolveReports(target, lobbyID, database.VoteCancelled)
+	return Reports.Reset(target, lobbyID)
+}
+
+// GetReportHistory returns the !rep votes in the lobby, and/or with the
+// player as the voter or target, latest first. A lobbyID of 0 or an empty
+// steamID matches everything.
+func GetReportHistory(lobbyID uint, steamID string) ([]database.VoteRecord, error) {
+	return Reports.History(lobbyID, steamID)
+}
+
+func countReports(target string, lobbyID uint) int {
+	return Reports.Count(target, lobbyID)
+}
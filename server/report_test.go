@@ -3,6 +3,7 @@ package server
 import (
 	"testing"
 
+	"github.com/TF2Stadium/Pauling/database"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -29,3 +30,39 @@ func TestNewReport(t *testing.T) {
 	assert.Zero(t, countReports(target, lobbyID))
 	assert.False(t, hasReported(source, target, lobbyID))
 }
+
+// TestReportHistory doesn't run in parallel, since other tests use the same
+// tables
+func TestReportHistory(t *testing.T) {
+	var lobbyID uint = 4
+
+	// clean up after previous runs
+	ResetReportCount("2", lobbyID)
+	db.Table("vote_records").Where("lobby_id = ?", lobbyID).Delete(&database.VoteRecord{})
+
+	assert.NoError(t, newReport("1", "2", lobbyID))
+	assert.NoError(t, newReport("3", "2", lobbyID))
+	resolveReports("2", lobbyID, database.VoteTimedOut)
+	assert.NoError(t, newReport("1", "4", lobbyID))
+
+	records, err := GetReportHistory(lobbyID, "")
+	assert.NoError(t, err)
+	if assert.Len(t, records, 3) {
+		assert.Equal(t, "4", records[0].Target)
+		assert.Equal(t, database.VotePending, records[0].Outcome)
+		assert.Nil(t, records[0].ResolvedAt)
+		assert.Equal(t, database.VoteTimedOut, records[2].Outcome)
+		assert.NotNil(t, records[2].ResolvedAt)
+	}
+
+	records, err = GetReportHistory(lobbyID, "3")
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	assert.NoError(t, CancelReports("4", lobbyID))
+	records, _ = GetReportHistory(lobbyID, "4")
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, database.VoteCancelled, records[0].Outcome)
+	}
+	assert.Zero(t, countReports("4", lobbyID))
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/TF2Stadium/Pauling/helpers"
+	"github.com/TF2Stadium/Pauling/server/stats"
+	"github.com/TF2Stadium/PlayerStatsScraper/steamid"
+)
+
+// lobbyStats is the last scoreboard of a lobby, kept around after the
+// server stops being managed
+type lobbyStats struct {
+	LobbyID    uint   `gorm:"primary_key"`
+	Scoreboard string // JSON encoded stats.Scoreboard
+	UpdatedAt  time.Time
+}
+
+// StatsPayload is the payload for statsUpdate events
+type StatsPayload struct {
+	stats.Scoreboard
+	Final bool // true if the match has ended
+}
+
+// Scoreboard returns the current stats, with community IDs for SteamIDs
+func (s *Server) Scoreboard() stats.Scoreboard {
+	board := s.stats.Scoreboard()
+	for i, player := range board.Players {
+		if commID, err := steamid.SteamIdToCommId(player.SteamID); err == nil {
+			board.Players[i].SteamID = commID
+		}
+	}
+
+	return board
+}
+
+func (s *Server) publishStats() {
+	publishEvent(Event{
+		Name:    StatsUpdate,
+		LobbyID: s.LobbyId,
+		Payload: &StatsPayload{Scoreboard: s.Scoreboard()},
+	})
+}
+
+// publishFinalStats publishes and stores the scoreboard once the match is
+// over
+func (s *Server) publishFinalStats() {
+	board := s.Scoreboard()
+
+	publishEvent(Event{
+		Name:    StatsUpdate,
+		LobbyID: s.LobbyId,
+		Payload: &StatsPayload{Scoreboard: board, Final: true},
+	})
+
+	bytes, _ := json.Marshal(board)
+	db.Table("lobby_stats").Where("lobby_id = ?", s.LobbyId).Delete(&lobbyStats{})
+	err := db.Table("lobby_stats").Create(&lobbyStats{LobbyID: s.LobbyId, Scoreboard: string(bytes)}).Error
+	if err != nil {
+		helpers.Logger.Errorf("#%d: Couldn't save stats: %v", s.LobbyId, err)
+	}
+}
+
+// GetStats returns the live scoreboard for a lobby, or the final one if the
+// lobby has ended
+func GetStats(lobbyID uint) (stats.Scoreboard, error) {
+	var board stats.Scoreboard
+
+	if s, err := GetServer(lobbyID); err == nil {
+		return s.Scoreboard(), nil
+	}
+
+	var saved lobbyStats
+	err := db.Table("lobby_stats").Where("lobby_id = ?", lobbyID).First(&saved).Error
+	if err != nil {
+		return board, ErrNoStats
+	}
+
+	err = json.Unmarshal([]byte(saved.Scoreboard), &board)
+	return board, err
+}
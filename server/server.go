@@ -1,8 +1,8 @@
 package server
 
 import (
+	"bytes"
 	"fmt"
-	"regexp"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -13,6 +13,8 @@ import (
 	"github.com/TF2Stadium/Pauling/config"
 	"github.com/TF2Stadium/Pauling/database"
 	"github.com/TF2Stadium/Pauling/helpers"
+	"github.com/TF2Stadium/Pauling/metrics"
+	"github.com/TF2Stadium/Pauling/server/stats"
 	"github.com/TF2Stadium/PlayerStatsScraper/steamid"
 	"github.com/TF2Stadium/TF2RconWrapper"
 )
@@ -24,33 +26,125 @@ type Server struct {
 	Whitelist string
 
 	LobbyId uint
+	Started time.Time // when the server was set up for the lobby
 
 	mapMu        sync.RWMutex
-	repTimer     map[string]*time.Timer
+	repTimer     map[string]*repVote // by team + slot
 	StopVerifier chan struct{}
 
-	source *TF2RconWrapper.Source
-	rcon   *TF2RconWrapper.TF2RconConnection
-	Info   gameserver.ServerRecord
+	sourceMu sync.Mutex
+	source   *TF2RconWrapper.Source // nil until connected
+	secret   string                 // log secret, kept while resuming
+	rcon     *rconConn
+	Info     gameserver.ServerRecord
 
 	curplayers *int32
 	ended      *int32
+	verifying  *int32
+	detached   *int32
+
+	// unix time in nanoseconds the tournament started at, 0 before
+	matchStarted *int64
+
+	detach       chan struct{} // closed by Detach
+	detachOnce   sync.Once
+	verifierDone chan struct{} // closed when StartVerifier returns
+
+	stats  *stats.Stats
+	lines  chan string // log lines from the listener, parsed by tailLogs
+	tailMu sync.Mutex  // held while parsing lines
+	logsMu sync.Mutex
+	logs   bytes.Buffer // lines parsed so far, uploaded at GameOver
+
+	demoMu sync.Mutex
+	demos  DemoInfo
+
+	streamMu sync.Mutex
+	streams  map[*LogStream]bool
+
+	sourcemod *int32 // pluginUnknown, pluginPresent or pluginMissing
+
+	cmdMu   sync.Mutex
+	lastRun map[string]time.Time // command name + commID -> last run
+
+	chatMu     sync.Mutex
+	chatWindow map[string]*chatWindow // by community ID
+
+	teamsMu     sync.Mutex
+	teams       map[string]string // cached by lobbyTeams
+	teamsLoaded time.Time
+
+	kickMu    sync.Mutex
+	kickVotes map[string]*kickVote // by target's community ID
+	kicked    map[string]bool      // community IDs kicked with !kick
+
+	pauseMu sync.Mutex
+	pause   pauseState
+
+	votes VoteRules // for !rep
+
+	done     chan struct{} // closed when the server stops listening
+	stopOnce sync.Once
 }
 
 func NewServer() *Server {
 	s := &Server{
-		repTimer:     make(map[string]*time.Timer),
+		repTimer:     make(map[string]*repVote),
+		rcon:         new(rconConn),
 		StopVerifier: make(chan struct{}, 1),
 		curplayers:   new(int32),
 		ended:        new(int32),
+		verifying:    new(int32),
+		matchStarted: new(int64),
+		detached:     new(int32),
+		sourcemod:    new(int32),
+		detach:       make(chan struct{}),
+		verifierDone: make(chan struct{}),
+		stats:        stats.New(),
+		lines:        make(chan string, 1024),
+		done:         make(chan struct{}),
+		streams:      make(map[*LogStream]bool),
+		lastRun:      make(map[string]time.Time),
+		chatWindow:   make(map[string]*chatWindow),
+		kickVotes:    make(map[string]*kickVote),
+		kicked:       make(map[string]bool),
+		pause:        pauseState{used: make(map[string]int)},
 	}
 
 	return s
 }
 
+// StopListening stops receiving logs from the server and stops the
+// verifier. Calling it more than once does nothing.
 func (s *Server) StopListening() {
-	Listener.RemoveSource(s.source, s.rcon)
-	s.StopVerifier <- struct{}{}
+	s.stopOnce.Do(func() {
+		s.removeSource()
+		close(s.done)
+		s.StopVerifier <- struct{}{}
+	})
+}
+
+// setSource sets the source logs are received from
+func (s *Server) setSource(source *TF2RconWrapper.Source) {
+	s.sourceMu.Lock()
+	s.source = source
+	s.sourceMu.Unlock()
+}
+
+// removeSource stops receiving logs, if the server was connected
+func (s *Server) removeSource() {
+	s.sourceMu.Lock()
+	defer s.sourceMu.Unlock()
+
+	if s.source != nil {
+		Listener.RemoveSource(s.source, s.rcon.conn())
+	}
+}
+
+// End stops managing the server, it can be called more than once
+func (s *Server) End() {
+	DeleteServer(s.LobbyId)
+	s.StopListening()
 }
 
 func (s *Server) GetPlayers() ([]TF2RconWrapper.Player, error) {
@@ -89,16 +183,26 @@ func (s *Server) Say(text string) error {
 
 func (s *Server) StartVerifier(ticker *time.Ticker) {
 	var err error
-	defer DeleteServer(s.LobbyId)
+	atomic.StoreInt32(s.verifying, 1)
+	defer func() {
+		atomic.StoreInt32(s.verifying, 0)
+		DeleteServer(s.LobbyId)
+		if atomic.LoadInt32(s.detached) == 0 {
+			forgetServer(s.LobbyId)
+		}
+		close(s.verifierDone)
+	}()
 
 	_, err = s.rcon.Query("status")
 	if err != nil {
 		err = s.rcon.Reconnect(5 * time.Minute)
 
 		if err != nil {
-			publishEvent(Event{
-				Name:    DisconnectedFromServer,
-				LobbyID: s.LobbyId})
+			if atomic.LoadInt32(s.detached) == 0 {
+				publishEvent(Event{
+					Name:    DisconnectedFromServer,
+					LobbyID: s.LobbyId})
+			}
 			return
 		}
 	}
@@ -118,15 +222,129 @@ func (s *Server) StartVerifier(ticker *time.Ticker) {
 			ticker.Stop()
 			s.rcon.Close()
 			return
+		case <-s.detach:
+			helpers.Logger.Debugf("#%d: Detaching from server", s.LobbyId)
+			s.rcon.RemoveTag("TF2Stadium")
+			ticker.Stop()
+			s.rcon.Close()
+			return
 		}
 	}
 }
 
+// detachTimeout is how long Detach waits for the verifier to stop, which
+// takes until it's done if it's reconnecting
+const detachTimeout = 10 * time.Second
+
+// Detach stops managing the server without ending the lobby, which stays
+// saved to be resumed when Pauling starts again. The server stops sending
+// logs to Pauling, and message is said on it if it isn't empty. Open votes
+// are saved with their deadlines, and continue once it's resumed.
+func (s *Server) Detach(message string) {
+	atomic.StoreInt32(s.detached, 1)
+	s.stopVotes()
+	s.saveState()
+	if message != "" {
+		s.rcon.Say(message)
+	}
+
+	s.removeSource()
+	s.stopOnce.Do(func() { close(s.done) })
+
+	if s.Verifying() {
+		s.detachOnce.Do(func() { close(s.detach) })
+		select {
+		case <-s.verifierDone:
+		case <-time.After(detachTimeout):
+			// still reconnecting, it'll stop once it's done
+			helpers.Logger.Warningf("#%d: Verifier didn't stop, detaching anyway", s.LobbyId)
+		}
+	} else {
+		s.rcon.Close()
+	}
+}
+
+// repVote is an open !rep vote, which times out at deadline
+type repVote struct {
+	target   string
+	team     string
+	slot     string
+	deadline time.Time
+	timer    *time.Timer // nil until the vote's server is resumed
+}
+
+// startRepTimer resets the votes against the target if the vote is still
+// open at its deadline. s.mapMu has to be held.
+func (s *Server) startRepTimer(vote *repVote) {
+	vote.timer = time.AfterFunc(vote.deadline.Sub(time.Now()), func() {
+		s.mapMu.Lock()
+		current := s.repTimer[vote.team+vote.slot] == vote
+		if current {
+			delete(s.repTimer, vote.team+vote.slot)
+		}
+		s.mapMu.Unlock()
+		if !current {
+			return
+		}
+
+		resolveReports(vote.target, s.LobbyId, database.VoteTimedOut)
+		ResetReportCount(vote.target, s.LobbyId)
+		say := fmt.Sprintf("Reporting %s %s failed, couldn't get enough votes in %s.", strings.ToUpper(vote.team), strings.ToUpper(vote.slot), formatDuration(time.Duration(s.votes.Window)))
+		s.replyVoters(s.repVoters(vote.team), say)
+	})
+}
+
+// repVoters returns the team voting on a player of the lobby team: the
+// player's team, or "" for both teams if cross-team votes count
+func (s *Server) repVoters(team string) string {
+	if s.votes.CrossTeam {
+		return ""
+	}
+	return team
+}
+
+// stopVotes stops the timers of open !rep and !kick votes. The votes are
+// kept, so that they're saved with the server's state and continue with
+// their deadlines when it's resumed.
+func (s *Server) stopVotes() {
+	s.mapMu.Lock()
+	for _, vote := range s.repTimer {
+		if vote.timer != nil {
+			vote.timer.Stop()
+		}
+	}
+	s.mapMu.Unlock()
+
+	s.kickMu.Lock()
+	for _, vote := range s.kickVotes {
+		if vote.timer != nil {
+			vote.timer.Stop()
+		}
+	}
+	s.kickMu.Unlock()
+}
+
+// resumeVotes starts the timers of the votes restored by restoreState
+func (s *Server) resumeVotes() {
+	s.mapMu.Lock()
+	for _, vote := range s.repTimer {
+		s.startRepTimer(vote)
+	}
+	s.mapMu.Unlock()
+
+	s.kickMu.Lock()
+	for _, vote := range s.kickVotes {
+		req := vote.req
+		s.startKickTimer(vote, func() { s.kickVoteExpired(req) })
+	}
+	s.kickMu.Unlock()
+}
+
 func (s *Server) Setup() error {
 	helpers.Logger.Debugf("#%d: Connecting to %s", s.LobbyId, s.Info.Host)
+	s.Started = time.Now()
 
-	var err error
-	s.rcon, err = TF2RconWrapper.NewTF2RconConnection(s.Info.Host, s.Info.RconPassword)
+	err := s.rcon.connect(s.Info.Host, s.Info.RconPassword)
 	if err != nil {
 		return err
 	}
@@ -144,7 +362,13 @@ func (s *Server) Setup() error {
 		return kickErr
 	}
 
-	s.rcon.Query("mp_tournament 1; mp_tournament_readymode 1")
+	s.pause.rules = loadPauseRules(s.League)
+	s.votes = loadVoteRules(s.League, s.Type)
+	readyMode := 0
+	if s.pause.rules.ReadyMode {
+		readyMode = 1
+	}
+	s.rcon.Query(fmt.Sprintf("mp_tournament 1; mp_tournament_readymode %d", readyMode))
 	// change map,
 	helpers.Logger.Debugf("#%d: Changing Map", s.LobbyId)
 	err = s.rcon.ChangeMap(s.Map)
@@ -161,18 +385,12 @@ func (s *Server) Setup() error {
 	}
 
 	helpers.Logger.Debugf("#%d: Creating listener", s.LobbyId)
-	eventlistener := &TF2RconWrapper.EventListener{
-		PlayerConnected:     s.PlayerConnected,
-		PlayerDisconnected:  s.PlayerDisconnected,
-		PlayerGlobalMessage: s.PlayerGlobalMessage,
-		GameOver:            s.GameOver,
-		CVarChange:          s.CVarChange,
-		TournamentStarted:   s.TournamentStarted,
-		RconCommand:         s.RconCommand,
-	}
-
-	s.source = Listener.AddSource(eventlistener, s.rcon)
-	database.SetSecret(s.source.Secret, s.Info.ID)
+	source := Listener.AddSource(s.eventListener(), s.rcon.conn())
+	s.secret = source.Secret
+	s.setSource(source)
+	go s.tailLogs()
+	go s.trackDemos()
+	database.SetSecret(s.secret, s.Info.ID)
 
 	s.rcon.AddTag("TF2Stadium")
 	s.rcon.Query("tftrue_no_hats 0; mp_timelimit 0; mp_tournament 1; mp_tournament_restart")
@@ -187,6 +405,46 @@ func (s *Server) Setup() error {
 	return nil
 }
 
+// Resume reconnects to a server which was set up before Pauling restarted,
+// and listens for its logs with the same secret. Unlike Setup, players aren't
+// kicked, and the map and configs are left alone.
+func (s *Server) Resume() error {
+	err := s.rcon.connect(s.Info.Host, s.Info.RconPassword)
+	if err != nil {
+		return err
+	}
+
+	s.pause.rules = loadPauseRules(s.League)
+	s.votes = loadVoteRules(s.League, s.Type)
+	s.setSource(Listener.AddSourceSecret(s.secret, s.eventListener(), s.rcon.conn()))
+	go s.tailLogs()
+	go s.trackDemos()
+	s.rcon.AddTag("TF2Stadium")
+	s.rcon.QueryNoResp("sv_logsecret " + s.secret + "; logaddress_add " + externalIP + ":" + config.Constants.LogsPort)
+
+	players, err := s.rcon.GetPlayers()
+	if err == nil {
+		atomic.StoreInt32(s.curplayers, int32(len(players)))
+	}
+
+	helpers.Logger.Debugf("#%d: Resumed", s.LobbyId)
+	return nil
+}
+
+func (s *Server) eventListener() *TF2RconWrapper.EventListener {
+	return &TF2RconWrapper.EventListener{
+		PlayerConnected:     s.PlayerConnected,
+		PlayerDisconnected:  s.PlayerDisconnected,
+		PlayerGlobalMessage: s.PlayerGlobalMessage,
+		PlayerTeamMessage:   s.PlayerTeamMessage,
+		GameOver:            s.GameOver,
+		CVarChange:          s.CVarChange,
+		TournamentStarted:   s.TournamentStarted,
+		RconCommand:         s.RconCommand,
+		LogLine:             s.logLine,
+	}
+}
+
 func (s *Server) execWhitelist() {
 	// whitelist
 	_, err := s.rcon.Query(fmt.Sprintf("tftrue_whitelist_id %s", s.Whitelist))
@@ -275,13 +533,14 @@ func (s *Server) Verify() bool {
 	password, err := s.rcon.GetServerPassword()
 
 	if err == nil {
-		players, _ := s.rcon.GetPlayers()
+		players, err := s.rcon.GetPlayers()
 		publishEvent(Event{
-			Name:    "playersList",
+			Name:    PlayersList,
+			LobbyID: s.LobbyId,
 			Players: players,
 		})
 
-		s.rcon.QueryNoResp("sv_logsecret " + s.source.Secret + "; logaddress_add " + externalIP + ":" + config.Constants.LogsPort)
+		s.rcon.QueryNoResp("sv_logsecret " + s.secret + "; logaddress_add " + externalIP + ":" + config.Constants.LogsPort)
 
 		if password == s.Info.ServerPassword {
 			return true
@@ -311,6 +570,36 @@ func (s *Server) hasEnded() bool {
 	return atomic.LoadInt32(s.ended) == 1
 }
 
+// matchStartedAt returns when the tournament started, or the zero time if
+// it hasn't yet
+func (s *Server) matchStartedAt() time.Time {
+	nsec := atomic.LoadInt64(s.matchStarted)
+	if nsec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nsec)
+}
+
+// HasEnded reports whether the match has ended
+func (s *Server) HasEnded() bool {
+	return s.hasEnded()
+}
+
+// PlayerCount returns the number of lobby players that have connected
+func (s *Server) PlayerCount() int {
+	return int(atomic.LoadInt32(s.curplayers))
+}
+
+// Verifying reports whether the verifier is running
+func (s *Server) Verifying() bool {
+	return atomic.LoadInt32(s.verifying) == 1
+}
+
+// ExecWhitelist sets the item whitelist on the server again
+func (s *Server) ExecWhitelist() {
+	s.execWhitelist()
+}
+
 func (s *Server) KickAll() error {
 	_, err := s.rcon.Query("kickall")
 
@@ -318,9 +607,7 @@ func (s *Server) KickAll() error {
 }
 
 var (
-	rReport      = regexp.MustCompile(`^!rep\s+(.+)\s+(.+).*`)
-	rFirstSubArg = regexp.MustCompile(`^!sub\s+(.+)`)
-
+	// default !rep thresholds, see VoteRules
 	repsNeeded = map[format.Format]int{
 		format.Sixes:      5,
 		format.Debug:      2,
@@ -332,72 +619,101 @@ var (
 	}
 )
 
-func slot(f format.Format) string {
-	switch f {
-	case format.Sixes:
-		return "scout1/scout2/demoman/pocket/roamer/medic"
-	case format.Highlander:
-		return "scout/soldier/pyro/demoman/heavy/engineer/medic/spy/sniper"
-	case format.Ultiduo:
-		return "soldier/medic"
-	case format.Bball:
-		return "soldier1/soldier2"
-	case format.Prolander:
-		return "scout/soldier/demo/medic/sniper/flex1/flex2"
-	default:
-		return "class name"
+func isTeam(argTeam string) bool {
+	switch argTeam {
+	case "our", "their", "red", "blu", "blue":
+		return true
 	}
+	return false
+}
 
+// resolveTeam returns the team ("red" or "blu") meant by argTeam when said
+// by the player source, or "" if argTeam isn't a team.
+func (s *Server) resolveTeam(source, argTeam string) string {
+	switch argTeam {
+	case "their":
+		if database.GetTeam(s.LobbyId, s.Type, source) == "red" {
+			return "blu"
+		}
+		return "red"
+	case "our":
+		return database.GetTeam(s.LobbyId, s.Type, source)
+	case "blu", "red":
+		return argTeam
+	case "blue":
+		return "blu"
+	}
+	return ""
 }
 
-func (s *Server) report(data TF2RconWrapper.PlayerData) {
-	var team string
+// report handles !rep, args are the words after it
+func (s *Server) report(data TF2RconWrapper.PlayerData, args []string) {
+	var team, argSlot, target string
 
-	matches := rReport.FindStringSubmatch(data.Text)
-	if len(matches) != 3 {
-		s.rcon.Say("Usage: !rep our/their/red/blu " + slot(s.Type))
+	if len(args) == 0 {
+		s.replyLines(data, slotUsage("!rep", s.Type))
 		return
 	}
 
-	argTeam := strings.ToLower(matches[1])
-	argSlot := strings.ToLower(matches[2])
-
 	source, _ := steamid.SteamIdToCommId(data.SteamId)
 
-	team = database.GetTeam(s.LobbyId, s.Type, source)
-	//	helpers.Logger.Debug(team)
+	if len(args) >= 2 && isTeam(strings.ToLower(args[0])) {
+		// !rep <team> <slot>
+		team = s.resolveTeam(source, strings.ToLower(args[0]))
+		if team == "" {
+			s.reply(data, message("unknownTeam", "!rep"))
+			return
+		}
 
-	switch argTeam {
-	case "their":
-		if team == "red" {
-			team = "blu"
-		} else {
-			team = "red"
+		argSlot = resolveSlot(s.Type, args[1])
+		if argSlot == "" {
+			s.replyLines(data, slotHelp(message("validSlots", "!rep"), s.Type))
+			return
 		}
-	case "our":
-		// team = team
-	case "blu", "red":
-		team = argTeam
-	case "blue":
-		team = "blu"
-	default:
-		s.rcon.Say("Usage: !rep our/their/red/blu slotname")
-		return
-	}
 
-	target, err := database.GetSteamIDFromSlot(team, argSlot, s.LobbyId, s.Type)
-	if err != nil {
-		var slots string
+		var err error
+		target, err = database.GetSteamIDFromSlot(team, argSlot, s.LobbyId, s.Type)
+		if err != nil {
+			s.reply(data, "!rep: Nobody is in that slot")
+			return
+		}
+	} else {
+		// !rep <name>
+		player, ok := s.findPlayer(data, strings.Join(args, " "))
+		if !ok {
+			return
+		}
+		target, team, argSlot = player.SteamID, player.Team, player.Class
+	}
 
-		s.rcon.Say("!rep: valid slots - " + slots)
+	if database.IsReported(s.LobbyId, target) {
+		s.reply(data, "!rep: Player has already been reported")
 		return
 	}
 
-	if database.IsReported(s.LobbyId, target) {
-		s.rcon.Say("!rep: Player has already been reported")
+	sourceTeam := database.GetTeam(s.LobbyId, s.Type, source)
+	if sourceTeam == "" {
+		s.reply(data, "!rep: Only players in the lobby can vote.")
 		return
 	}
 
+	if target != source {
+		if !s.votes.CrossTeam && sourceTeam != team {
+			s.reply(data, "!rep: Only the player's team can vote to replace them.")
+			return
+		}
+
+		started := s.matchStartedAt()
+		if wait := s.votes.OpensIn(started, time.Now()); wait > 0 {
+			if started.IsZero() {
+				s.reply(data, fmt.Sprintf("!rep: Votes open %s after the match starts.", formatDuration(wait)))
+			} else {
+				s.reply(data, fmt.Sprintf("!rep: Votes open in %s.", formatDuration(wait)))
+			}
+			return
+		}
+	}
+
 	if target == source {
 		// !rep'ing themselves
 		publishEvent(Event{
@@ -407,28 +723,31 @@ func (s *Server) report(data TF2RconWrapper.PlayerData) {
 			Self:    true})
 
 		say := fmt.Sprintf("Reporting player %s (%s)", data.Username, data.SteamId)
-		s.rcon.Say(say)
+		s.replyTeam(sourceTeam, say)
 		return
 	}
 
-	err = newReport(source, target, s.LobbyId)
+	err := newReport(source, target, s.LobbyId)
 
 	if err != nil {
 		if _, ok := err.(*repError); ok {
-			s.rcon.Say("!rep: You have already voted.")
+			s.reply(data, "!rep: You have already voted.")
 		} else {
-			s.rcon.Say(err.Error())
+			s.reply(data, err.Error())
 			helpers.Logger.Errorf("#%d: %v", s.LobbyId, err)
 		}
 		return
 	}
+	metrics.Reports.Inc("")
 
 	curReps := countReports(target, s.LobbyId)
 	name := database.GetNameFromSteamID(target)
+	needed := s.votes.Needed(s.Type)
 
-	switch curReps {
-	case repsNeeded[s.Type]:
+	switch {
+	case curReps >= needed:
 		//Got needed number of reports, ask helen to substitute player
+		resolveReports(target, s.LobbyId, database.VoteSucceeded)
 		publishEvent(Event{
 			Name:    PlayerSubstituted,
 			SteamID: target,
@@ -437,35 +756,34 @@ func (s *Server) report(data TF2RconWrapper.PlayerData) {
 		// tell timeout goroutine to stop (It is possible that the map
 		// entry will not exist if only 1 report is needed (such as debug
 		// lobbies))
-		s.mapMu.RLock()
-		timer, ok := s.repTimer[team+argSlot]
-		s.mapMu.RUnlock()
-
-		if ok && timer.Stop() {
-			s.mapMu.Lock()
+		s.mapMu.Lock()
+		if vote, ok := s.repTimer[team+argSlot]; ok {
+			if vote.timer != nil {
+				vote.timer.Stop()
+			}
 			delete(s.repTimer, team+argSlot)
-			s.mapMu.Unlock()
-
 		}
+		s.mapMu.Unlock()
 
 		say := fmt.Sprintf("Reporting %s %s: %s", strings.ToUpper(team), strings.ToUpper(argSlot), name)
 		s.rcon.Say(say)
 
-	case 1:
-		//first report happened, reset reps two minute later to 0, unless told to stop
-		timer := time.AfterFunc(2*time.Minute, func() {
-			ResetReportCount(target, s.LobbyId)
-			say := fmt.Sprintf("Reporting %s %s failed, couldn't get enough votes in 2 minutes.", strings.ToUpper(team), strings.ToUpper(argSlot))
-			s.rcon.Say(say)
-
-		})
+	case curReps == 1:
+		//first report happened, reset reps when the window is over, unless told to stop
+		vote := &repVote{
+			target:   target,
+			team:     team,
+			slot:     argSlot,
+			deadline: time.Now().Add(time.Duration(s.votes.Window)),
+		}
 
 		s.mapMu.Lock()
-		s.repTimer[team+argSlot] = timer
+		s.startRepTimer(vote)
+		s.repTimer[team+argSlot] = vote
 		s.mapMu.Unlock()
 	}
-	say := fmt.Sprintf("Got %d votes for reporting %s (%d needed)", curReps, name, repsNeeded[s.Type])
-	s.rcon.Say(say)
+	say := fmt.Sprintf("Got %d votes for reporting %s (%d needed)", curReps, name, needed)
+	s.replyVoters(s.repVoters(team), say)
 
 	return
 }
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/TF2Stadium/Pauling/helpers"
+)
+
+var (
+	// gameOvers tracks GameOver handlers which are still uploading logs and
+	// publishing events
+	gameOvers sync.WaitGroup
+
+	// set when Shutdown starts waiting for gameOvers, after which no new
+	// GameOver handlers are started
+	gameOversMu  sync.Mutex
+	shuttingDown bool
+)
+
+// flushTimeout is how long Shutdown tries sending pending events for
+const flushTimeout = 10 * time.Second
+
+// startGameOver adds a GameOver handler to gameOvers, unless Shutdown is
+// already waiting for them
+func startGameOver() bool {
+	gameOversMu.Lock()
+	defer gameOversMu.Unlock()
+
+	if shuttingDown {
+		return false
+	}
+	gameOvers.Add(1)
+	return true
+}
+
+// Shutdown detaches from every managed server, so that they can be resumed
+// later, waits for ended matches to be processed, sends pending events and
+// closes connections. message is said on every server if it isn't empty.
+func Shutdown(message string) {
+	var wg sync.WaitGroup
+
+	for _, s := range AllServers() {
+		wg.Add(1)
+		go func(s *Server) {
+			s.Detach(message)
+			wg.Done()
+		}(s)
+	}
+	wg.Wait()
+	helpers.Logger.Info("Detached from all servers")
+
+	gameOversMu.Lock()
+	shuttingDown = true
+	gameOversMu.Unlock()
+	gameOvers.Wait()
+	flushOutbox(flushTimeout)
+	helpers.Logger.Info("Sent pending events")
+
+	if eventsMQ != nil {
+		eventsMQ.Close()
+	}
+	if logsMQ != nil {
+		logsMQ.Close()
+	}
+	db.Close()
+}
@@ -0,0 +1,289 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/TF2Stadium/Pauling/config"
+	"github.com/TF2Stadium/Pauling/helpers"
+	"github.com/TF2Stadium/Pauling/metrics"
+)
+
+// EventSink is something events are sent to. Events are sent to every sink
+// in the order they were published, and a sink returning an error gets the
+// same event again after a while, so events may be delivered more than once
+// (see Event). Each sink is sent events on its own, so a sink that can't be
+// reached only holds up its own events.
+type EventSink interface {
+	Name() string
+	// body is e encoded as JSON, as sent to Helen
+	Publish(e Event, body []byte) error
+}
+
+var (
+	sinksMu sync.RWMutex
+	sinks   []*sinkWorker
+
+	// LocalEvents is the in-process sink, if enabled in the config
+	LocalEvents *LocalSink
+
+	// ErrSinkClosed is returned by sinks that won't be accepting events
+	// anymore
+	ErrSinkClosed = errors.New("Event sink closed.")
+)
+
+func setupSinks() {
+	for _, name := range config.Constants.EventSinks {
+		switch name {
+		case "amqp":
+			AddSink(connectMQ())
+		case "webhook":
+			AddSink(newWebhookSink(config.Constants.WebhookURL, config.Constants.WebhookSecret))
+		case "local":
+			LocalEvents = NewLocalSink()
+			AddSink(LocalEvents)
+		default:
+			helpers.Logger.Fatalf("Unknown event sink %s", name)
+		}
+	}
+
+	go publishOutbox()
+}
+
+// sinkCursor is the ID of the last outbox event sent to a sink
+type sinkCursor struct {
+	Sink    string `gorm:"primary_key"`
+	EventID uint
+}
+
+// sinkWorker sends outbox events to a single sink, in ID order
+type sinkWorker struct {
+	sink   EventSink
+	notify chan struct{}
+
+	mu     sync.Mutex // held while sending events
+	cursor uint       // ID of the last event sent to sink
+	closed bool
+}
+
+// AddSink makes all events which haven't been sent to every sink yet, and
+// all events published from now on, also go to sink
+func AddSink(sink EventSink) {
+	cursor := sinkCursor{Sink: sink.Name()}
+	err := db.Table("sink_cursors").Where("sink = ?", sink.Name()).
+		Attrs(sinkCursor{EventID: lastSentEvent()}).FirstOrCreate(&cursor).Error
+	if err != nil {
+		helpers.Logger.Fatal(err)
+	}
+
+	w := &sinkWorker{
+		sink:   sink,
+		notify: make(chan struct{}, 1),
+		cursor: cursor.EventID,
+	}
+
+	sinksMu.Lock()
+	sinks = append(sinks, w)
+	sinksMu.Unlock()
+
+	go w.run()
+	w.wake()
+	helpers.Logger.Info("Sending events to %s", sink.Name())
+}
+
+// lastSentEvent returns the ID of the last event sent to every sink
+func lastSentEvent() uint {
+	var id sql.NullInt64
+	db.Table("outbox_events").Where("sent = ?", true).Select("max(id)").Row().Scan(&id)
+	return uint(id.Int64)
+}
+
+// notifySinks wakes up every sink, to send events added to the outbox
+func notifySinks() {
+	sinksMu.RLock()
+	defer sinksMu.RUnlock()
+
+	for _, w := range sinks {
+		w.wake()
+	}
+}
+
+func (w *sinkWorker) wake() {
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// run sends events to the sink every time it's woken up, retrying with
+// backoff until the sink has all events. It only gives up if the sink has
+// been closed.
+func (w *sinkWorker) run() {
+	for range w.notify {
+		backoff := time.Second
+
+		for {
+			err := w.flush()
+			if err == nil {
+				break
+			}
+			if err == ErrSinkClosed {
+				helpers.Logger.Warningf("Event sink %s closed", w.sink.Name())
+				return
+			}
+
+			time.Sleep(backoff)
+			if backoff < time.Minute {
+				backoff *= 2
+			}
+		}
+	}
+}
+
+// flush sends every event after the cursor to the sink, stopping at the
+// first event it fails to send.
+func (w *sinkWorker) flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrSinkClosed
+	}
+
+	for {
+		var pending []outboxEvent
+
+		err := db.Table("outbox_events").Where("id > ?", w.cursor).Order("id").Limit(100).Find(&pending).Error
+		if err != nil {
+			helpers.Logger.Error(err.Error())
+			return err
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		for _, e := range pending {
+			var event Event
+			if err := json.Unmarshal([]byte(e.Body), &event); err != nil {
+				helpers.Logger.Errorf("Couldn't decode event %d: %v", e.ID, err)
+			} else if err := w.sink.Publish(event, []byte(e.Body)); err != nil {
+				if err == ErrSinkClosed {
+					w.closed = true
+					return err
+				}
+				helpers.Logger.Errorf("#%d: Couldn't send %s event to %s: %v", event.LobbyID, event.Name, w.sink.Name(), err)
+				metrics.EventsFailed.Inc(w.sink.Name())
+				return err
+			} else {
+				metrics.EventsPublished.Inc(w.sink.Name())
+			}
+
+			w.cursor = e.ID
+			err := db.Table("sink_cursors").Where("sink = ?", w.sink.Name()).Update("event_id", e.ID).Error
+			if err != nil {
+				helpers.Logger.Errorf("Couldn't save the cursor of %s: %v", w.sink.Name(), err)
+			}
+		}
+	}
+}
+
+// markSent marks the events every sink has been sent, so that they can be
+// pruned
+func markSent() {
+	sinksMu.RLock()
+	var names []string
+	for _, w := range sinks {
+		names = append(names, w.sink.Name())
+	}
+	sinksMu.RUnlock()
+
+	if len(names) == 0 {
+		return
+	}
+
+	var sent sql.NullInt64
+	err := db.Table("sink_cursors").Where("sink IN (?)", names).Select("min(event_id)").Row().Scan(&sent)
+	if err != nil {
+		helpers.Logger.Error(err.Error())
+		return
+	}
+
+	err = db.Table("outbox_events").Where("sent = ? AND id <= ?", false, sent.Int64).Updates(map[string]interface{}{
+		"sent":    true,
+		"sent_at": time.Now(),
+	}).Error
+	if err != nil {
+		helpers.Logger.Error(err.Error())
+	}
+}
+
+// LocalSink fans events out to subscribers in the same process
+type LocalSink struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]bool
+}
+
+// Subscription receives events from a LocalSink on C. Events are dropped if
+// C isn't being read from fast enough.
+type Subscription struct {
+	C     chan Event
+	names map[string]bool
+
+	sink *LocalSink
+}
+
+func NewLocalSink() *LocalSink {
+	return &LocalSink{subs: make(map[*Subscription]bool)}
+}
+
+func (*LocalSink) Name() string { return "local" }
+
+// Subscribe returns a subscription to events with the given names, or all
+// events if no names are given.
+func (l *LocalSink) Subscribe(names ...string) *Subscription {
+	sub := &Subscription{
+		C:     make(chan Event, 64),
+		names: make(map[string]bool),
+		sink:  l,
+	}
+	for _, name := range names {
+		sub.names[name] = true
+	}
+
+	l.mu.Lock()
+	l.subs[sub] = true
+	l.mu.Unlock()
+
+	return sub
+}
+
+func (l *LocalSink) Publish(e Event, _ []byte) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for sub := range l.subs {
+		if len(sub.names) != 0 && !sub.names[e.Name] {
+			continue
+		}
+
+		select {
+		case sub.C <- e:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Close stops the subscription, and closes C
+func (sub *Subscription) Close() {
+	sub.sink.mu.Lock()
+	if sub.sink.subs[sub] {
+		delete(sub.sink.subs, sub)
+		close(sub.C)
+	}
+	sub.sink.mu.Unlock()
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalSink(t *testing.T) {
+	t.Parallel()
+	sink := NewLocalSink()
+	all := sink.Subscribe()
+	ended := sink.Subscribe(MatchEnded)
+
+	sink.Publish(Event{Name: PlayerConnected, LobbyID: 1}, nil)
+	sink.Publish(Event{Name: MatchEnded, LobbyID: 1}, nil)
+
+	assert.Equal(t, PlayerConnected, (<-all.C).Name)
+	assert.Equal(t, MatchEnded, (<-all.C).Name)
+	assert.Equal(t, MatchEnded, (<-ended.C).Name)
+	assert.Len(t, ended.C, 0)
+
+	ended.Close()
+	_, ok := <-ended.C
+	assert.False(t, ok)
+	assert.NoError(t, sink.Publish(Event{Name: MatchEnded}, nil))
+}
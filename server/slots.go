@@ -0,0 +1,163 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/TF2Stadium/Pauling/config"
+)
+
+// slotAliases are groups of names for the same slot. A name is resolved to
+// the name in its group which is a slot in the lobby's format. Names which
+// could mean several slots in a format, like "scout" in sixes, aren't
+// aliases.
+var slotAliases = [][]string{
+	{"pocket", "soldier1"},
+	{"roamer", "soldier2"},
+	{"soldier", "solly"},
+	{"demoman", "demo"},
+	{"heavy", "heavyweapons", "hoovy"},
+	{"engineer", "engie", "engy"},
+	{"medic", "med"},
+}
+
+// slotNames returns the names of the slots in a team in f, in slot order,
+// as used by the format package
+func slotNames(f format.Format) []string {
+	var names []string
+	for slot := 0; slot < format.NumberOfClassesMap[f]; slot++ {
+		_, class, err := format.GetSlotTeamClass(f, slot)
+		if err != nil {
+			break
+		}
+		names = append(names, class)
+	}
+	return names
+}
+
+// aliases returns the other names that resolve to the slot name in f
+func aliases(f format.Format, name string) []string {
+	var names []string
+	for _, group := range slotAliases {
+		for _, alias := range group {
+			if alias != name && resolveSlot(f, alias) == name && !contains(names, alias) {
+				names = append(names, alias)
+			}
+		}
+	}
+	return names
+}
+
+func contains(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSlot returns the format package's name for the slot called name in
+// f, or "" if there's no such slot
+func resolveSlot(f format.Format, name string) string {
+	name = strings.ToLower(name)
+	slots := slotNames(f)
+	if contains(slots, name) {
+		return name
+	}
+
+	for _, group := range slotAliases {
+		if !contains(group, name) {
+			continue
+		}
+		for _, slot := range group {
+			if contains(slots, slot) {
+				return slot
+			}
+		}
+	}
+	return ""
+}
+
+// maxChatLen is the longest chat message the game shows, longer ones are
+// cut off
+const maxChatLen = 127
+
+// chatLines joins items with ", " after prefix, starting a new line whenever
+// the current one would get longer than maxChatLen
+func chatLines(prefix string, items []string) []string {
+	var lines []string
+	line := prefix
+	for i, item := range items {
+		switch {
+		case i == 0:
+			line += item
+		case len(line)+len(", ")+len(item) > maxChatLen:
+			lines = append(lines, line)
+			line = item
+		default:
+			line += ", " + item
+		}
+	}
+	return append(lines, line)
+}
+
+// slotHelp lists the slots in f with their aliases after prefix, like
+// "scout1, pocket (soldier1), demoman (demo)", over as many lines
+// as needed
+func slotHelp(prefix string, f format.Format) []string {
+	var help []string
+	for _, name := range slotNames(f) {
+		if names := aliases(f, name); len(names) != 0 {
+			name += " (" + strings.Join(names, ", ") + ")"
+		}
+		help = append(help, name)
+	}
+	return chatLines(prefix, help)
+}
+
+// messages are the translations of the texts said by !rep and !kick, by
+// PAULING_LANGUAGE. Texts which are followed by a list of slots end with the
+// separator, the slots are split over lines by chatLines. "help <command>"
+// translates the command's Help.
+var messages = map[string]map[string]string{
+	"en": {
+		"usage":       "Usage: %s our/their/red/blu <slot>, or %[1]s <name>. Slots: ",
+		"validSlots":  "%s: valid slots - ",
+		"unknownTeam": "%s: team has to be our, their, red or blu",
+	},
+	"de": {
+		"usage":       "Benutzung: %s our/their/red/blu <Slot>, oder %[1]s <Name>. Slots: ",
+		"validSlots":  "%s: gültige Slots - ",
+		"unknownTeam": "%s: Team muss our, their, red oder blu sein",
+		"help rep":    "stimme dafür, einen Spieler zu ersetzen",
+		"help kick":   "stimme für den Kick eines Mitspielers oder Lobbyfremden, Leader kicken sofort",
+	},
+	"fr": {
+		"usage":       "Utilisation : %s our/their/red/blu <slot>, ou %[1]s <nom>. Slots : ",
+		"validSlots":  "%s : slots valides - ",
+		"unknownTeam": "%s : l'équipe doit être our, their, red ou blu",
+		"help rep":    "voter pour remplacer un joueur",
+		"help kick":   "voter pour kicker un coéquipier ou un joueur hors du lobby, le leader kicke direct",
+	},
+}
+
+// message returns the text for key in the configured language
+func message(key string, args ...interface{}) string {
+	return messageIn(config.Constants.Language, key, args...)
+}
+
+// messageIn returns the text for key in lang, falling back to English
+func messageIn(lang, key string, args ...interface{}) string {
+	text, ok := messages[lang][key]
+	if !ok {
+		text = messages["en"][key]
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// slotUsage is the usage text for a command taking a team and slot in f
+func slotUsage(command string, f format.Format) []string {
+	return slotHelp(message("usage", command), f)
+}
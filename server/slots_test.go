@@ -0,0 +1,85 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/TF2Stadium/Pauling/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveSlot(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		format format.Format
+		name   string
+		slot   string
+	}{
+		{format.Sixes, "Scout1", "scout1"},
+		{format.Sixes, "soldier1", "pocket"},
+		{format.Sixes, "soldier2", "roamer"},
+		{format.Sixes, "demo", "demoman"},
+		{format.Sixes, "scout", ""},
+		{format.Highlander, "engie", "engineer"},
+		{format.Highlander, "scout2", ""},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.slot, resolveSlot(c.format, c.name), "%s in %s", c.name, formatMap[c.format])
+	}
+}
+
+func TestSlotNames(t *testing.T) {
+	t.Parallel()
+	for f := range formatMap {
+		names := slotNames(f)
+		assert.Len(t, names, format.NumberOfClassesMap[f], formatMap[f])
+		for _, name := range names {
+			assert.Equal(t, name, resolveSlot(f, name))
+		}
+	}
+}
+
+func TestSlotHelp(t *testing.T) {
+	t.Parallel()
+	help := slotHelp(messageIn("en", "validSlots", "!rep"), format.Sixes)
+	if assert.Len(t, help, 1) {
+		assert.Equal(t, "!rep: valid slots - ", help[0][:len("!rep: valid slots - ")])
+		assert.Contains(t, help[0], "pocket (soldier1)")
+		assert.Contains(t, help[0], "demoman (demo)")
+	}
+}
+
+func TestMessagesFitChat(t *testing.T) {
+	t.Parallel()
+	for _, lang := range config.Languages {
+		assert.Contains(t, messages, lang)
+
+		for f := range formatMap {
+			for _, command := range []string{"!rep", "!kick"} {
+				lines := slotHelp(messageIn(lang, "usage", command), f)
+				lines = append(lines, slotHelp(messageIn(lang, "validSlots", command), f)...)
+				lines = append(lines, messageIn(lang, "unknownTeam", command))
+
+				for _, line := range lines {
+					assert.True(t, len(line) <= maxChatLen, "%s: %q is too long", lang, line)
+				}
+			}
+		}
+
+		for _, name := range []string{"rep", "kick"} {
+			cmd := lookupCommand(name)
+			line := cmd.usage() + " - " + commandHelp(cmd, lang)
+			assert.True(t, len(line) <= maxChatLen, "%s: %q is too long", lang, line)
+		}
+	}
+}
+
+func TestChatLines(t *testing.T) {
+	t.Parallel()
+	item := strings.Repeat("a", 60)
+	assert.Equal(t, []string{"x: "}, chatLines("x: ", nil))
+	assert.Equal(t, []string{"x: " + item + ", " + item, item}, chatLines("x: ", []string{item, item, item}))
+	assert.Equal(t, []string{"x: " + item, item + item}, chatLines("x: ", []string{item, item + item}))
+}
@@ -0,0 +1,344 @@
+package server
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/TF2Stadium/Pauling/helpers"
+)
+
+// serverState is the durable copy of a managed server, used for resuming
+// lobbies after Pauling restarts
+type serverState struct {
+	LobbyID   uint   `gorm:"primary_key"`
+	Info      string // JSON encoded gameserver.ServerRecord
+	Map       string
+	Type      int
+	League    string
+	Whitelist string
+	Secret    string // log secret used by the server's source
+	Started   time.Time
+
+	MatchStarted time.Time // zero until the tournament starts
+
+	Ended      bool
+	PausesUsed string // JSON encoded pauses used by each team
+	Paused     bool
+	PausedTeam string
+	PausedAt   time.Time
+	Kicked     string // JSON encoded community IDs kicked with !kick
+	RepVotes   string // JSON encoded open !rep votes
+	KickVotes  string // JSON encoded open !kick votes
+}
+
+// savedVote is an open !rep or !kick vote, as saved with the server's state.
+// The voters of !rep votes are kept by Reports.
+type savedVote struct {
+	Target   string
+	Name     string `json:",omitempty"`
+	Team     string
+	Slot     string
+	Voters   []string `json:",omitempty"`
+	Deadline time.Time
+}
+
+const (
+	// resumeAttempts is how many times ResumeServers tries to reconnect to
+	// a server before giving up on its lobby
+	resumeAttempts = 5
+	// resumeBackoff is the wait after the first failed attempt, doubled
+	// after every attempt
+	resumeBackoff = 5 * time.Second
+)
+
+// SaveServer stores s in the sqlite database, so that it can be resumed
+// by ResumeServers if Pauling restarts
+func SaveServer(s *Server) error {
+	info, err := json.Marshal(s.Info)
+	if err != nil {
+		return err
+	}
+
+	state := &serverState{
+		LobbyID:   s.LobbyId,
+		Info:      string(info),
+		Map:       s.Map,
+		Type:      int(s.Type),
+		League:    s.League,
+		Whitelist: s.Whitelist,
+		Secret:    s.secret,
+		Started:   s.Started,
+		Ended:     s.hasEnded(),
+
+		MatchStarted: s.matchStartedAt(),
+	}
+
+	s.pauseMu.Lock()
+	used, err := json.Marshal(s.pause.used)
+	state.PausesUsed = string(used)
+	state.Paused = s.pause.paused
+	state.PausedTeam = s.pause.team
+	state.PausedAt = s.pause.pausedAt
+	s.pauseMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.kickMu.Lock()
+	kicked, err := json.Marshal(s.kicked)
+	state.Kicked = string(kicked)
+	s.kickMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var repVotes []savedVote
+	s.mapMu.RLock()
+	for _, vote := range s.repTimer {
+		repVotes = append(repVotes, savedVote{Target: vote.target, Team: vote.team, Slot: vote.slot, Deadline: vote.deadline})
+	}
+	s.mapMu.RUnlock()
+	votes, err := json.Marshal(repVotes)
+	if err != nil {
+		return err
+	}
+	state.RepVotes = string(votes)
+
+	var kickVotes []savedVote
+	s.kickMu.Lock()
+	for _, vote := range s.kickVotes {
+		saved := savedVote{
+			Target:   vote.req.target,
+			Name:     vote.req.name,
+			Team:     vote.req.team,
+			Slot:     vote.req.slot,
+			Deadline: vote.deadline,
+		}
+		for voter := range vote.voters {
+			saved.Voters = append(saved.Voters, voter)
+		}
+		kickVotes = append(kickVotes, saved)
+	}
+	s.kickMu.Unlock()
+	votes, err = json.Marshal(kickVotes)
+	if err != nil {
+		return err
+	}
+	state.KickVotes = string(votes)
+
+	tx := db.Begin()
+	if err := tx.Table("server_states").Where("lobby_id = ?", s.LobbyId).Delete(&serverState{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Table("server_states").Create(state).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// saveState saves the server's current match state, like pauses, so it
+// survives restarts
+func (s *Server) saveState() {
+	if err := SaveServer(s); err != nil {
+		helpers.Logger.Errorf("#%d: Couldn't save server state: %v", s.LobbyId, err)
+	}
+}
+
+func forgetServer(lobbyID uint) error {
+	return db.Table("server_states").Where("lobby_id = ?", lobbyID).Delete(&serverState{}).Error
+}
+
+// ResumeServers reconnects to every server saved with SaveServer, and
+// starts listening for logs and verifying them again. Servers are
+// registered right away, so Helen's RPCs and SetupServer see the lobby while
+// it's being resumed. Servers which can't be reached are retried in the
+// background, and their lobbies are only given up on after resumeAttempts
+// tries. Lobbies which had ended are forgotten instead.
+func ResumeServers() {
+	var states []serverState
+
+	err := db.Table("server_states").Find(&states).Error
+	if err != nil {
+		helpers.Logger.Error(err.Error())
+		return
+	}
+
+	for _, state := range states {
+		if state.Ended {
+			helpers.Logger.Info("#%d: Not resuming server, the lobby has ended", state.LobbyID)
+			forgetServer(state.LobbyID)
+			continue
+		}
+
+		s := NewServer()
+		s.LobbyId = state.LobbyID
+		s.Map = state.Map
+		s.Type = format.Format(state.Type)
+		s.League = state.League
+		s.Whitelist = state.Whitelist
+		s.Started = state.Started
+		s.secret = state.Secret
+
+		if err := json.Unmarshal([]byte(state.Info), &s.Info); err != nil {
+			helpers.Logger.Errorf("#%d: Couldn't decode server record: %v", state.LobbyID, err)
+			forgetServer(state.LobbyID)
+			continue
+		}
+
+		s.demos, _ = GetDemos(s.LobbyId)
+		s.restoreState(state)
+		SetServer(s.LobbyId, s)
+
+		helpers.Logger.Info("#%d: Resuming server %s", s.LobbyId, s.Info.Host)
+		if err := s.Resume(); err != nil {
+			helpers.Logger.Warningf("#%d: Couldn't resume server, retrying: %v", s.LobbyId, err)
+			go retryResume(s)
+			continue
+		}
+
+		s.resumed()
+	}
+}
+
+// isCurrent reports whether s is still the server managed for its lobby,
+// which it isn't anymore once it's ended or Helen sets up another one
+func (s *Server) isCurrent() bool {
+	current, err := GetServer(s.LobbyId)
+	return err == nil && current == s
+}
+
+// retryResume tries resuming s again with backoff, and gives up on the lobby
+// if the server still can't be reached
+func retryResume(s *Server) {
+	backoff := resumeBackoff
+	var err error
+
+	for attempt := 1; attempt < resumeAttempts; attempt++ {
+		time.Sleep(backoff)
+		backoff *= 2
+
+		if !s.isCurrent() {
+			helpers.Logger.Info("#%d: Not resuming server anymore, the lobby was ended or set up again", s.LobbyId)
+			return
+		}
+
+		if err = s.Resume(); err == nil {
+			if !s.isCurrent() {
+				// ended or set up again while reconnecting
+				s.StopListening()
+				s.rcon.Close()
+				return
+			}
+			s.resumed()
+			return
+		}
+		helpers.Logger.Warningf("#%d: Couldn't resume server (attempt %d/%d): %v", s.LobbyId, attempt+1, resumeAttempts, err)
+	}
+
+	if !s.isCurrent() {
+		return
+	}
+
+	helpers.Logger.Errorf("#%d: Giving up on resuming server: %v", s.LobbyId, err)
+	DeleteServer(s.LobbyId)
+	forgetServer(s.LobbyId)
+	publishEvent(Event{
+		Name:    DisconnectedFromServer,
+		LobbyID: s.LobbyId})
+}
+
+// restoreState restores the match state saved by SaveServer
+func (s *Server) restoreState(state serverState) {
+	if state.Ended {
+		atomic.StoreInt32(s.ended, 1)
+	}
+	if !state.MatchStarted.IsZero() {
+		atomic.StoreInt64(s.matchStarted, state.MatchStarted.UnixNano())
+	}
+
+	if state.Kicked != "" {
+		s.kickMu.Lock()
+		if err := json.Unmarshal([]byte(state.Kicked), &s.kicked); err != nil {
+			helpers.Logger.Errorf("#%d: Couldn't decode kicked players: %v", s.LobbyId, err)
+		}
+		s.kickMu.Unlock()
+	}
+
+	s.restoreVotes(state)
+
+	s.pauseMu.Lock()
+	defer s.pauseMu.Unlock()
+
+	if state.PausesUsed != "" {
+		if err := json.Unmarshal([]byte(state.PausesUsed), &s.pause.used); err != nil {
+			helpers.Logger.Errorf("#%d: Couldn't decode pauses: %v", s.LobbyId, err)
+		}
+	}
+	s.pause.paused = state.Paused
+	s.pause.team = state.PausedTeam
+	s.pause.pausedAt = state.PausedAt
+}
+
+// restoreVotes restores the open votes saved by SaveServer, their timers
+// are started by resumed
+func (s *Server) restoreVotes(state serverState) {
+	var repVotes, kickVotes []savedVote
+	if state.RepVotes != "" {
+		if err := json.Unmarshal([]byte(state.RepVotes), &repVotes); err != nil {
+			helpers.Logger.Errorf("#%d: Couldn't decode !rep votes: %v", s.LobbyId, err)
+		}
+	}
+	if state.KickVotes != "" {
+		if err := json.Unmarshal([]byte(state.KickVotes), &kickVotes); err != nil {
+			helpers.Logger.Errorf("#%d: Couldn't decode !kick votes: %v", s.LobbyId, err)
+		}
+	}
+
+	s.mapMu.Lock()
+	for _, saved := range repVotes {
+		s.repTimer[saved.Team+saved.Slot] = &repVote{
+			target:   saved.Target,
+			team:     saved.Team,
+			slot:     saved.Slot,
+			deadline: saved.Deadline,
+		}
+	}
+	s.mapMu.Unlock()
+
+	s.kickMu.Lock()
+	for _, saved := range kickVotes {
+		vote := &kickVote{
+			req:      kickRequest{target: saved.Target, name: saved.Name, team: saved.Team, slot: saved.Slot},
+			voters:   make(map[string]bool),
+			deadline: saved.Deadline,
+		}
+		for _, voter := range saved.Voters {
+			vote.voters[voter] = true
+		}
+		s.kickVotes[saved.Target] = vote
+	}
+	s.kickMu.Unlock()
+}
+
+// resumed starts verifying a server after Resume succeeded, and the timers
+// of votes and of a pause which were running when Pauling stopped
+func (s *Server) resumed() {
+	s.resumeVotes()
+
+	s.pauseMu.Lock()
+	if s.pause.paused {
+		s.pause.unpause = make(chan struct{})
+		left := s.pause.rules.MaxDuration - time.Since(s.pause.pausedAt)
+		if left < 0 {
+			left = 0
+		}
+		go s.pauseTimer(s.pause.unpause, left)
+	}
+	s.pauseMu.Unlock()
+
+	go s.StartVerifier(time.NewTicker(time.Second * 10))
+}
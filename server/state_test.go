@@ -0,0 +1,45 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveVotes(t *testing.T) {
+	t.Parallel()
+	var lobbyID uint = 5
+	defer forgetServer(lobbyID)
+
+	s := NewServer()
+	s.LobbyId = lobbyID
+	deadline := time.Now().Add(time.Minute).Round(time.Second)
+
+	s.mapMu.Lock()
+	s.repTimer["redscout1"] = &repVote{target: "1", team: "red", slot: "scout1", deadline: deadline}
+	s.mapMu.Unlock()
+	_, _, err := s.castKickVote(kickBy("a", "red"), 3, time.Minute, func() {})
+	assert.NoError(t, err)
+	s.stopVotes()
+
+	assert.NoError(t, SaveServer(s))
+	var state serverState
+	assert.NoError(t, db.Table("server_states").Where("lobby_id = ?", lobbyID).First(&state).Error)
+
+	resumed := NewServer()
+	resumed.LobbyId = lobbyID
+	resumed.restoreVotes(state)
+
+	if vote, ok := resumed.repTimer["redscout1"]; assert.True(t, ok) {
+		assert.Equal(t, "1", vote.target)
+		assert.True(t, deadline.Equal(vote.deadline))
+		assert.Nil(t, vote.timer)
+	}
+	if vote, ok := resumed.kickVotes["target"]; assert.True(t, ok) {
+		assert.Equal(t, "red", vote.req.team)
+		assert.Equal(t, "scout1", vote.req.slot)
+		assert.Equal(t, map[string]bool{"a": true}, vote.voters)
+		assert.True(t, vote.deadline.After(time.Now()))
+	}
+}
@@ -0,0 +1,183 @@
+// Package stats aggregates per-player match statistics from TF2 log lines.
+package stats
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Player struct {
+	SteamID string
+	Name    string
+	Team    string // red/blu
+
+	Kills    int
+	Deaths   int
+	Assists  int
+	Damage   int
+	Heals    int
+	Ubers    int
+	Captures int
+}
+
+type Scoreboard struct {
+	Players  []Player // sorted by team, then kills
+	RedScore int
+	BluScore int
+}
+
+// Stats is the running scoreboard for a single match. It's safe for
+// concurrent use.
+type Stats struct {
+	mu       sync.RWMutex
+	players  map[string]*Player
+	redScore int
+	bluScore int
+	changed  bool
+}
+
+const player = `"(.+?)<\d+><([^>]*)><([^>]*)>"`
+
+var (
+	rPrefix    = regexp.MustCompile(`^L \d{2}/\d{2}/\d{4} - \d{2}:\d{2}:\d{2}: `)
+	rKill      = regexp.MustCompile(`^` + player + ` killed ` + player + ` with "[^"]*"`)
+	rSuicide   = regexp.MustCompile(`^` + player + ` committed suicide`)
+	rAssist    = regexp.MustCompile(`^` + player + ` triggered "kill assist" against ` + player)
+	rDamage    = regexp.MustCompile(`^` + player + ` triggered "damage" (?:against ` + player + ` )?\(damage "(\d+)"\)`)
+	rHeal      = regexp.MustCompile(`^` + player + ` triggered "healed" against ` + player + ` \(healing "(\d+)"\)`)
+	rUber      = regexp.MustCompile(`^` + player + ` triggered "chargedeployed"`)
+	rCapture   = regexp.MustCompile(`^Team "(Red|Blue)" triggered "pointcaptured"`)
+	rCapper    = regexp.MustCompile(`\(player\d+ ` + player + `\)`)
+	rRoundWin  = regexp.MustCompile(`^World triggered "Round_Win" \(winner "(Red|Blue)"\)`)
+	feignDeath = `(customkill "feign_death")`
+)
+
+func New() *Stats {
+	return &Stats{players: make(map[string]*Player)}
+}
+
+func team(logTeam string) string {
+	switch logTeam {
+	case "Red":
+		return "red"
+	case "Blue":
+		return "blu"
+	}
+	return ""
+}
+
+// player returns the player with the given name, steam ID and team as logged,
+// creating it if needed. Must be called with mu held.
+func (s *Stats) player(name, steamID, logTeam string) *Player {
+	p, ok := s.players[steamID]
+	if !ok {
+		p = &Player{SteamID: steamID}
+		s.players[steamID] = p
+	}
+
+	p.Name = name
+	if t := team(logTeam); t != "" {
+		p.Team = t
+	}
+	return p
+}
+
+// ParseLine updates the stats with a single log line. Lines which don't
+// affect the stats are ignored.
+func (s *Stats) ParseLine(line string) {
+	line = rPrefix.ReplaceAllString(strings.TrimSpace(line), "")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case rKill.MatchString(line):
+		if strings.Contains(line, feignDeath) {
+			return
+		}
+		m := rKill.FindStringSubmatch(line)
+		s.player(m[1], m[2], m[3]).Kills++
+		s.player(m[4], m[5], m[6]).Deaths++
+
+	case rSuicide.MatchString(line):
+		m := rSuicide.FindStringSubmatch(line)
+		s.player(m[1], m[2], m[3]).Deaths++
+
+	case rAssist.MatchString(line):
+		m := rAssist.FindStringSubmatch(line)
+		s.player(m[1], m[2], m[3]).Assists++
+
+	case rDamage.MatchString(line):
+		m := rDamage.FindStringSubmatch(line)
+		damage, _ := strconv.Atoi(m[7])
+		s.player(m[1], m[2], m[3]).Damage += damage
+
+	case rHeal.MatchString(line):
+		m := rHeal.FindStringSubmatch(line)
+		heals, _ := strconv.Atoi(m[7])
+		s.player(m[1], m[2], m[3]).Heals += heals
+
+	case rUber.MatchString(line):
+		m := rUber.FindStringSubmatch(line)
+		s.player(m[1], m[2], m[3]).Ubers++
+
+	case rCapture.MatchString(line):
+		for _, m := range rCapper.FindAllStringSubmatch(line, -1) {
+			s.player(m[1], m[2], m[3]).Captures++
+		}
+
+	case rRoundWin.MatchString(line):
+		if rRoundWin.FindStringSubmatch(line)[1] == "Red" {
+			s.redScore++
+		} else {
+			s.bluScore++
+		}
+
+	default:
+		return
+	}
+
+	s.changed = true
+}
+
+// Changed reports whether the stats have changed since the last call to
+// Changed
+func (s *Stats) Changed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := s.changed
+	s.changed = false
+	return changed
+}
+
+type byTeamKills []Player
+
+func (p byTeamKills) Len() int      { return len(p) }
+func (p byTeamKills) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byTeamKills) Less(i, j int) bool {
+	if p[i].Team != p[j].Team {
+		return p[i].Team > p[j].Team // red first
+	}
+	return p[i].Kills > p[j].Kills
+}
+
+// Scoreboard returns a copy of the current stats
+func (s *Stats) Scoreboard() Scoreboard {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	board := Scoreboard{
+		RedScore: s.redScore,
+		BluScore: s.bluScore,
+	}
+	for _, p := range s.players {
+		board.Players = append(board.Players, *p)
+	}
+	sort.Sort(byTeamKills(board.Players))
+
+	return board
+}
@@ -0,0 +1,56 @@
+package stats
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var lines = []string{
+	`L 10/18/2016 - 20:01:02: "scout<3><[U:1:1]><Red>" killed "medic<4><[U:1:2]><Blue>" with "scattergun" (attacker_position "1 2 3") (victim_position "4 5 6")`,
+	`L 10/18/2016 - 20:01:02: "demo<5><[U:1:3]><Red>" triggered "kill assist" against "medic<4><[U:1:2]><Blue>" (assister_position "1 2 3")`,
+	`L 10/18/2016 - 20:01:03: "scout<3><[U:1:1]><Red>" triggered "damage" against "medic<4><[U:1:2]><Blue>" (damage "54") (weapon "scattergun")`,
+	`L 10/18/2016 - 20:01:04: "medic<4><[U:1:2]><Blue>" triggered "healed" against "soldier<6><[U:1:4]><Blue>" (healing "27")`,
+	`L 10/18/2016 - 20:01:05: "medic<4><[U:1:2]><Blue>" triggered "chargedeployed" (medigun "medigun")`,
+	`L 10/18/2016 - 20:01:06: "spy<7><[U:1:5]><Red>" killed "soldier<6><[U:1:4]><Blue>" with "knife" (customkill "feign_death")`,
+	`L 10/18/2016 - 20:01:07: "soldier<6><[U:1:4]><Blue>" committed suicide with "world" (attacker_position "1 2 3")`,
+	`L 10/18/2016 - 20:01:08: Team "Red" triggered "pointcaptured" (cp "0") (cpname "#cp_badlands_cap_2") (numcappers "2") (player1 "scout<3><[U:1:1]><Red>") (position1 "1 2 3") (player2 "demo<5><[U:1:3]><Red>") (position2 "4 5 6")`,
+	`L 10/18/2016 - 20:01:09: World triggered "Round_Win" (winner "Red")`,
+	`L 10/18/2016 - 20:01:10: "scout<3><[U:1:1]><Red>" say "gg"`,
+}
+
+func TestParseLine(t *testing.T) {
+	t.Parallel()
+	s := New()
+	for _, line := range lines {
+		s.ParseLine(line)
+	}
+	assert.True(t, s.Changed())
+	assert.False(t, s.Changed())
+
+	board := s.Scoreboard()
+	assert.Equal(t, 1, board.RedScore)
+	assert.Equal(t, 0, board.BluScore)
+
+	players := make(map[string]Player)
+	for _, p := range board.Players {
+		players[p.SteamID] = p
+	}
+
+	scout := players["[U:1:1]"]
+	assert.Equal(t, "red", scout.Team)
+	assert.Equal(t, 1, scout.Kills)
+	assert.Equal(t, 54, scout.Damage)
+	assert.Equal(t, 1, scout.Captures)
+
+	medic := players["[U:1:2]"]
+	assert.Equal(t, "blu", medic.Team)
+	assert.Equal(t, 1, medic.Deaths)
+	assert.Equal(t, 27, medic.Heals)
+	assert.Equal(t, 1, medic.Ubers)
+
+	assert.Equal(t, 1, players["[U:1:3]"].Assists)
+	assert.Equal(t, 1, players["[U:1:4]"].Deaths)
+	_, ok := players["[U:1:5]"]
+	assert.False(t, ok, "feign deaths shouldn't count as kills")
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TF2Stadium/Pauling/config"
+	"github.com/TF2Stadium/Pauling/helpers"
+	"github.com/TF2Stadium/Pauling/mq"
+	"github.com/TF2Stadium/TF2RconWrapper"
+	"github.com/streadway/amqp"
+)
+
+// LogLine is a single log line from a lobby's server
+type LogLine struct {
+	LobbyID uint
+	Type    string // see lineTypes
+	Text    string // without the timestamp
+	Time    time.Time
+}
+
+// LogStream receives a lobby's log lines on C, till the lobby ends. Lines are
+// dropped if C isn't being read from fast enough.
+type LogStream struct {
+	C     chan LogLine
+	types map[string]bool
+
+	server *Server
+}
+
+var (
+	rLinePrefix = regexp.MustCompile(`^L (\d{2}/\d{2}/\d{4} - \d{2}:\d{2}:\d{2}): `)
+
+	lineTypes = []struct {
+		name string
+		r    *regexp.Regexp
+	}{
+		{"kill", regexp.MustCompile(`^".+" (killed|committed suicide) `)},
+		{"damage", regexp.MustCompile(`^".+" triggered "damage"`)},
+		{"heal", regexp.MustCompile(`^".+" triggered "healed"`)},
+		{"uber", regexp.MustCompile(`^".+" triggered "chargedeployed"`)},
+		{"chat", regexp.MustCompile(`^".+" say(_team)? "`)},
+		{"capture", regexp.MustCompile(`^Team "\w+" triggered "pointcaptured"`)},
+		{"round", regexp.MustCompile(`^World triggered "(Round_\w+|Game_Over)"`)},
+		{"connection", regexp.MustCompile(`^".+" (connected|disconnected|entered the game)`)},
+		{"team", regexp.MustCompile(`^".+" (joined team|changed role to) "`)},
+		{"rcon", regexp.MustCompile(`^rcon from "`)},
+	}
+
+	logsMQ *mq.Supervisor
+
+	// the channel log lines are published on, replaced every time logsMQ
+	// reconnects
+	logsChannelMu sync.Mutex
+	logsChannel   *amqp.Channel
+)
+
+// parseLogLine splits line into the text after the timestamp, and the time
+// the server logged it at. The time is now if line has no timestamp.
+func parseLogLine(line string) (string, time.Time) {
+	line = strings.TrimSpace(line)
+
+	match := rLinePrefix.FindStringSubmatch(line)
+	if match == nil {
+		return line, time.Now()
+	}
+
+	text := line[len(match[0]):]
+	t, err := time.ParseInLocation(TF2RconWrapper.TimeFormat, match[1], time.Local)
+	if err != nil {
+		return text, time.Now()
+	}
+	return text, t
+}
+
+func lineType(text string) string {
+	for _, t := range lineTypes {
+		if t.r.MatchString(text) {
+			return t.name
+		}
+	}
+	return "other"
+}
+
+// StreamLogs returns a stream of the log lines of the given types, or all
+// lines if no types are given.
+func (s *Server) StreamLogs(types ...string) *LogStream {
+	stream := &LogStream{
+		C:      make(chan LogLine, 256),
+		types:  make(map[string]bool),
+		server: s,
+	}
+	for _, t := range types {
+		stream.types[t] = true
+	}
+
+	s.streamMu.Lock()
+	select {
+	case <-s.done:
+		close(stream.C)
+	default:
+		s.streams[stream] = true
+	}
+	s.streamMu.Unlock()
+
+	return stream
+}
+
+// Close stops the stream, and closes C
+func (stream *LogStream) Close() {
+	s := stream.server
+
+	s.streamMu.Lock()
+	if s.streams[stream] {
+		delete(s.streams, stream)
+		close(stream.C)
+	}
+	s.streamMu.Unlock()
+}
+
+// streamLine sends line to all streams, and the log exchange if it's enabled
+func (s *Server) streamLine(line string) {
+	text, t := parseLogLine(line)
+	if text == "" {
+		return
+	}
+
+	logLine := LogLine{
+		LobbyID: s.LobbyId,
+		Type:    lineType(text),
+		Text:    text,
+		Time:    t,
+	}
+
+	s.streamMu.Lock()
+	for stream := range s.streams {
+		if len(stream.types) != 0 && !stream.types[logLine.Type] {
+			continue
+		}
+
+		select {
+		case stream.C <- logLine:
+		default:
+		}
+	}
+	s.streamMu.Unlock()
+
+	if logsMQ != nil && logsMQ.State() == mq.Connected {
+		logsChannelMu.Lock()
+		channel := logsChannel
+		logsChannelMu.Unlock()
+
+		bytes, _ := json.Marshal(logLine)
+		channel.Publish(config.Constants.LogExchange, s.lobbyLabel()+"."+logLine.Type, false, false, amqp.Publishing{
+			ContentType: "application/json",
+			Body:        bytes,
+		})
+	}
+}
+
+// closeStreams closes every stream once the server stops listening
+func (s *Server) closeStreams() {
+	s.streamMu.Lock()
+	for stream := range s.streams {
+		delete(s.streams, stream)
+		close(stream.C)
+	}
+	s.streamMu.Unlock()
+}
+
+// connectLogExchange publishes all log lines to a fanout exchange, so that
+// they can be consumed over AMQP. Publishing is best effort, lines are
+// dropped while reconnecting.
+func connectLogExchange() {
+	logsMQ = mq.NewSupervisor("logs", config.Constants.RabbitMQURL, func(conn *amqp.Connection) error {
+		channel, err := conn.Channel()
+		if err != nil {
+			return err
+		}
+
+		err = channel.ExchangeDeclare(
+			config.Constants.LogExchange, // name
+			"fanout",                     // type
+			false,                        // durable
+			false,                        // auto-deleted
+			false,                        // internal
+			false,                        // no-wait
+			nil,                          // arguments
+		)
+		if err != nil {
+			return err
+		}
+
+		logsChannelMu.Lock()
+		logsChannel = channel
+		logsChannelMu.Unlock()
+		return nil
+	})
+
+	if err := logsMQ.Start(); err != nil {
+		helpers.Logger.Fatalf("Failed to connect to RabbitMQ - %s", err.Error())
+	}
+	helpers.Logger.Info("Sending log lines to exchange %s", config.Constants.LogExchange)
+}
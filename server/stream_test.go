@@ -0,0 +1,21 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLogLine(t *testing.T) {
+	t.Parallel()
+	text, at := parseLogLine(`L 05/12/2016 - 19:37:01: "Sniper<3><[U:1:1234]><Red>" triggered "chargedeployed"` + "\n")
+	assert.Equal(t, `"Sniper<3><[U:1:1234]><Red>" triggered "chargedeployed"`, text)
+	assert.Equal(t, time.Date(2016, time.May, 12, 19, 37, 1, 0, time.Local), at)
+	assert.Equal(t, "uber", lineType(text))
+
+	before := time.Now()
+	text, at = parseLogLine("not a log line")
+	assert.Equal(t, "not a log line", text)
+	assert.False(t, at.Before(before))
+}
@@ -3,13 +3,21 @@ package server
 import (
 	"errors"
 	"sync"
+
+	"github.com/TF2Stadium/Pauling/metrics"
 )
 
 var (
 	servers = make(map[uint]*Server)
 	mu      = new(sync.RWMutex)
 
+	managedServers = metrics.NewGauge("pauling_managed_servers", "Servers currently managed.", func() float64 {
+		return float64(CountServers())
+	})
+
 	ErrNoServer = errors.New("Server doesn't exist.")
+	ErrNoStats  = errors.New("No stats for this lobby.")
+	ErrNoDemos  = errors.New("No demos for this lobby.")
 )
 
 func GetServer(id uint) (s *Server, err error) {
@@ -37,3 +45,22 @@ func DeleteServer(id uint) {
 	delete(servers, id)
 	mu.Unlock()
 }
+
+func CountServers() int {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return len(servers)
+}
+
+// AllServers returns every managed server
+func AllServers() []*Server {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	all := make([]*Server, 0, len(servers))
+	for _, s := range servers {
+		all = append(all, s)
+	}
+	return all
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/TF2Stadium/Pauling/config"
+	"github.com/TF2Stadium/Pauling/metrics"
+)
+
+// tailLogs parses the log lines sent by the listener as they arrive, till the
+// server stops listening.
+func (s *Server) tailLogs() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	defer metrics.LogLines.Delete(s.lobbyLabel())
+	defer s.closeStreams()
+	lastUpdate := time.Now()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case line := <-s.lines:
+			s.parseLine(line)
+		case <-ticker.C:
+			if time.Since(lastUpdate) >= config.Constants.StatsInterval && s.stats.Changed() {
+				s.publishStats()
+				lastUpdate = time.Now()
+			}
+		}
+	}
+}
+
+// logLine is called by the listener with every log line the server sends.
+// Lines are queued for tailLogs, so that slow log streams don't hold up the
+// listener.
+func (s *Server) logLine(line string) {
+	atomic.StoreInt64(&lastLogLine, time.Now().UnixNano())
+
+	select {
+	case s.lines <- line:
+	case <-s.done:
+	}
+}
+
+// readLogs parses the lines queued by the listener which tailLogs hasn't
+// read yet
+func (s *Server) readLogs() {
+	for {
+		select {
+		case line := <-s.lines:
+			s.parseLine(line)
+		default:
+			return
+		}
+	}
+}
+
+// parseLine passes line on to the stats, demo tracking and log streams, and
+// keeps it for uploading
+func (s *Server) parseLine(line string) {
+	s.tailMu.Lock()
+	defer s.tailMu.Unlock()
+
+	metrics.LogLines.Inc(s.lobbyLabel())
+	s.stats.ParseLine(line)
+	s.streamLine(line)
+
+	s.logsMu.Lock()
+	s.logs.WriteString(line)
+	if len(line) == 0 || line[len(line)-1] != '\n' {
+		s.logs.WriteByte('\n')
+	}
+	s.logsMu.Unlock()
+}
+
+func (s *Server) lobbyLabel() string {
+	return strconv.FormatUint(uint64(s.LobbyId), 10)
+}
+
+// LogsSince returns the logs received after the first offset bytes, and the
+// offset to pass for reading the logs after those.
+func (s *Server) LogsSince(offset int) ([]byte, int) {
+	s.logsMu.Lock()
+	defer s.logsMu.Unlock()
+
+	data := s.logs.Bytes()
+	if offset > len(data) || offset < 0 {
+		offset = 0
+	}
+
+	return append([]byte(nil), data[offset:]...), len(data)
+}
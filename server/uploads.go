@@ -0,0 +1,106 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/TF2Stadium/Pauling/config"
+	"github.com/TF2Stadium/Pauling/helpers"
+	"github.com/TF2Stadium/Pauling/metrics"
+	"github.com/TF2Stadium/Pauling/server/logs"
+)
+
+// pendingUpload is a logs.tf upload that failed, and will be retried. The
+// logs themselves are read from the archive.
+type pendingUpload struct {
+	LobbyID     uint `gorm:"primary_key"`
+	Map         string
+	Attempts    int
+	NextAttempt time.Time
+}
+
+// LogsUploadedPayload is the payload for logsUploaded events
+type LogsUploadedPayload struct {
+	LogsID int
+}
+
+func uploadTitle(lobbyID uint) string {
+	return fmt.Sprintf("TF2Stadium Lobby #%d", lobbyID)
+}
+
+// queueUpload schedules the archived logs for a lobby to be uploaded again
+func queueUpload(lobbyID uint, mapName string) {
+	err := db.Table("pending_uploads").Create(&pendingUpload{
+		LobbyID:     lobbyID,
+		Map:         mapName,
+		NextAttempt: time.Now().Add(uploadBackoff(0)),
+	}).Error
+	if err != nil {
+		helpers.Logger.Errorf("#%d: Couldn't queue logs upload: %v", lobbyID, err)
+	}
+}
+
+// uploadBackoff returns how long to wait after the given number of failed
+// retries
+func uploadBackoff(attempts int) time.Duration {
+	backoff := time.Minute << uint(attempts)
+	if backoff > 6*time.Hour || backoff <= 0 {
+		backoff = 6 * time.Hour
+	}
+	return backoff
+}
+
+func retryUploads() {
+	for {
+		var due []pendingUpload
+
+		err := db.Table("pending_uploads").Where("next_attempt <= ?", time.Now()).Find(&due).Error
+		if err != nil {
+			helpers.Logger.Error(err.Error())
+		}
+
+		for _, upload := range due {
+			retryUpload(upload)
+		}
+
+		time.Sleep(time.Minute)
+	}
+}
+
+func retryUpload(upload pendingUpload) {
+	_, data, err := Archive.Get(upload.LobbyID)
+	if err != nil {
+		helpers.Logger.Errorf("#%d: Couldn't read archived logs, not uploading: %v", upload.LobbyID, err)
+		db.Table("pending_uploads").Delete(&upload)
+		return
+	}
+
+	logsID, err := logs.Upload(uploadTitle(upload.LobbyID), upload.Map, bytes.NewBuffer(data))
+	if err != nil {
+		metrics.Uploads.Inc("failure")
+		upload.Attempts++
+		if logs.Permanent(err) || upload.Attempts >= config.Constants.MaxUploadAttempts {
+			helpers.Logger.Errorf("#%d: Giving up on uploading logs: %v", upload.LobbyID, err)
+			db.Table("pending_uploads").Delete(&upload)
+			return
+		}
+
+		helpers.Logger.Warningf("#%d: Uploading logs failed (attempt %d): %v", upload.LobbyID, upload.Attempts, err)
+		upload.NextAttempt = time.Now().Add(uploadBackoff(upload.Attempts))
+		db.Table("pending_uploads").Save(&upload)
+		return
+	}
+
+	metrics.Uploads.Inc("success")
+	helpers.Logger.Info("#%d: Uploaded logs to logs.tf/%d", upload.LobbyID, logsID)
+	db.Table("pending_uploads").Delete(&upload)
+	Archive.SetLogsID(upload.LobbyID, logsID)
+
+	publishEvent(Event{
+		Name:    LogsUploaded,
+		LobbyID: upload.LobbyID,
+		LogsID:  logsID,
+		Payload: &LogsUploadedPayload{LogsID: logsID},
+	})
+}
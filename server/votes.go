@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/TF2Stadium/Pauling/config"
+	"github.com/TF2Stadium/Pauling/helpers"
+)
+
+// VoteRules are the rules for !rep votes in a format and league
+type VoteRules struct {
+	// Votes needed to replace a player
+	Votes int
+	// If set, the votes needed are this fraction of the team size, rounded
+	// up, instead of Votes
+	Fraction float64
+	// How long a vote stays open after the first !rep
+	Window config.Duration
+	// Whether players can vote to replace players on the other team
+	CrossTeam bool
+	// How long after the match started votes open
+	MinMatchTime config.Duration
+}
+
+// voteConfig is the format of configs/votes.json. Formats and leagues map
+// format names (as in formatMap) to rules, and only need to have the fields
+// which differ from the defaults. League rules override format rules.
+//
+//	{
+//		"Formats": {"sixes": {"Fraction": 0.75}},
+//		"Leagues": {"etf2l": {"sixes": {"Window": "3m"}}}
+//	}
+type voteConfig struct {
+	Formats map[string]json.RawMessage
+	Leagues map[string]map[string]json.RawMessage
+}
+
+func defaultVoteRules(f format.Format) VoteRules {
+	return VoteRules{
+		Votes:     repsNeeded[f],
+		Window:    config.Duration(2 * time.Minute),
+		CrossTeam: true,
+	}
+}
+
+// Needed returns the number of votes needed to replace a player
+func (r VoteRules) Needed(f format.Format) int {
+	needed := r.Votes
+	if r.Fraction > 0 {
+		needed = int(math.Ceil(r.Fraction * float64(format.NumberOfClassesMap[f])))
+	}
+	if needed < 1 {
+		needed = 1
+	}
+	return needed
+}
+
+// OpensIn returns how long until votes open, for a match which started at
+// started, or MinMatchTime if it hasn't started yet
+func (r VoteRules) OpensIn(started, now time.Time) time.Duration {
+	if started.IsZero() {
+		return time.Duration(r.MinMatchTime)
+	}
+	return time.Duration(r.MinMatchTime) - now.Sub(started)
+}
+
+// loadVoteRules reads the vote rules for the format and league from
+// configs/votes.json, using the defaults for anything not in it
+func loadVoteRules(league string, f format.Format) VoteRules {
+	rules := defaultVoteRules(f)
+
+	path, _ := filepath.Abs("./configs/votes.json")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			helpers.Logger.Errorf("Couldn't read %s: %v", path, err)
+		}
+		return rules
+	}
+
+	rules, err = parseVoteRules(data, league, f)
+	if err != nil {
+		helpers.Logger.Errorf("Couldn't parse %s: %v", path, err)
+		return defaultVoteRules(f)
+	}
+	return rules
+}
+
+func parseVoteRules(data []byte, league string, f format.Format) (VoteRules, error) {
+	rules := defaultVoteRules(f)
+
+	var config voteConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return rules, err
+	}
+
+	name := formatMap[f]
+	if raw, ok := config.Formats[name]; ok {
+		if err := json.Unmarshal(raw, &rules); err != nil {
+			return rules, err
+		}
+	}
+	if raw, ok := config.Leagues[league][name]; ok {
+		if err := json.Unmarshal(raw, &rules); err != nil {
+			return rules, err
+		}
+	}
+
+	return rules, nil
+}
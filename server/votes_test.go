@@ -0,0 +1,52 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TF2Stadium/Helen/models/lobby/format"
+	"github.com/TF2Stadium/Pauling/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVoteRules(t *testing.T) {
+	t.Parallel()
+	data := []byte(`{
+		"Formats": {"sixes": {"Fraction": 0.5}},
+		"Leagues": {"etf2l": {"sixes": {"Window": "3m", "CrossTeam": false}, "highlander": {"Votes": 4}}}
+	}`)
+
+	rules, err := parseVoteRules(data, "ugc", format.Sixes)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, rules.Needed(format.Sixes))
+	assert.Equal(t, config.Duration(2*time.Minute), rules.Window)
+	assert.True(t, rules.CrossTeam)
+
+	rules, err = parseVoteRules(data, "etf2l", format.Sixes)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, rules.Needed(format.Sixes))
+	assert.Equal(t, config.Duration(3*time.Minute), rules.Window)
+	assert.False(t, rules.CrossTeam)
+
+	rules, err = parseVoteRules(data, "ugc", format.Highlander)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultVoteRules(format.Highlander), rules)
+	assert.Equal(t, 6, rules.Needed(format.Highlander))
+
+	rules, err = parseVoteRules(data, "etf2l", format.Highlander)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, rules.Needed(format.Highlander))
+}
+
+func TestVotesOpen(t *testing.T) {
+	t.Parallel()
+	rules := VoteRules{MinMatchTime: config.Duration(5 * time.Minute)}
+	now := time.Now()
+
+	assert.Equal(t, 5*time.Minute, rules.OpensIn(time.Time{}, now))
+	assert.Equal(t, 3*time.Minute, rules.OpensIn(now.Add(-2*time.Minute), now))
+	assert.True(t, rules.OpensIn(now.Add(-6*time.Minute), now) <= 0)
+
+	rules.MinMatchTime = 0
+	assert.True(t, rules.OpensIn(time.Time{}, now) <= 0)
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/TF2Stadium/Pauling/helpers"
+)
+
+// webhookSink POSTs events as JSON to an URL. If secret is set, the body is
+// signed with HMAC-SHA256 and the signature is sent in the
+// X-Pauling-Signature header.
+//
+// Webhooks are best effort: an event is dropped after a few failed attempts,
+// so that a broken webhook doesn't hold up events for Helen.
+type webhookSink struct {
+	url    string
+	secret string
+	client *http.Client
+
+	retryDelay time.Duration // doubled after every failed attempt
+}
+
+const webhookAttempts = 3
+
+func newWebhookSink(url, secret string) *webhookSink {
+	if url == "" {
+		helpers.Logger.Fatal("The webhook event sink needs PAULING_WEBHOOK_URL to be set")
+	}
+
+	return &webhookSink{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: 10 * time.Second},
+
+		retryDelay: time.Second,
+	}
+}
+
+func (*webhookSink) Name() string { return "webhook" }
+
+func (w *webhookSink) Publish(e Event, body []byte) error {
+	var err error
+	delay := w.retryDelay
+
+	for i := 0; i < webhookAttempts; i++ {
+		if i != 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		if err = w.post(body); err == nil {
+			return nil
+		}
+	}
+
+	helpers.Logger.Errorf("#%d: Dropping %s event for webhook: %v", e.LobbyID, e.Name, err)
+	return nil
+}
+
+func (w *webhookSink) post(body []byte) error {
+	req, err := http.NewRequest("POST", w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != "" {
+		req.Header.Set("X-Pauling-Signature", "sha256="+sign(body, w.secret))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookSink(t *testing.T) {
+	t.Parallel()
+	body := []byte(`{"Name":"test","LobbyID":3}`)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	cases := []struct {
+		failures int32 // requests answered with an error before succeeding
+		attempts int32
+	}{
+		{0, 1},
+		{2, 3},
+		{5, webhookAttempts}, // dropped
+	}
+
+	for _, c := range cases {
+		var requests int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, _ := ioutil.ReadAll(r.Body)
+			assert.Equal(t, body, got)
+			assert.Equal(t, signature, r.Header.Get("X-Pauling-Signature"))
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+			if atomic.AddInt32(&requests, 1) <= c.failures {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}))
+
+		sink := newWebhookSink(ts.URL, "secret")
+		sink.retryDelay = 0
+		assert.NoError(t, sink.Publish(Event{Name: Test, LobbyID: 3}, body))
+		assert.Equal(t, c.attempts, atomic.LoadInt32(&requests), "%d failures", c.failures)
+
+		ts.Close()
+	}
+}